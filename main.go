@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	mylog "github/AHKLIC/Web/slog"
 	"github/AHKLIC/Web/work/dbm"
 	"github/AHKLIC/Web/work/until"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github/AHKLIC/Web/work/handle"
 
@@ -15,38 +21,59 @@ import (
 )
 
 // 路由注册（按功能分组）
-func RegisterRoutes(r *gin.Engine) {
+func RegisterRoutes(r *gin.Engine, h *handle.Handler, tokens dbm.TokenStore) {
+
+	// JWKS 公钥（其他服务据此验证本服务签发的 Token）
+	r.GET("/.well-known/jwks.json", handle.JWKSHandler)
 
 	// 公开路由组（无需认证）
 	public := r.Group("/api/public")
-	public.Use(until.PublicJWTMiddleware())
+	public.Use(until.RateLimitMiddleware(), until.PublicJWTMiddleware(tokens))
 	{
 
-		public.GET("/data/latest", handle.GetLatestCrawleData)
-		public.GET("/query/fuzzy/search", handle.SubmitFuzzyQuery)
+		public.GET("/data/latest", h.GetLatestCrawleData)
+		public.GET("/data/history", h.GetCrawleDataHistory)
+		public.GET("/data/diff", h.GetBatchDiff)
+		public.GET("/trend", h.GetTitleTrend)
+		public.GET("/topics/latest", h.GetLatestTopics)
+		public.GET("/query/fuzzy/search", h.SubmitFuzzyQuery)
 		// /api/public/query/fuzzy/result
-		public.GET("/query/fuzzy/result", handle.GetFuzzyQueryResult)
+		public.GET("/query/fuzzy/result", h.GetFuzzyQueryResult)
+		// /api/public/query/fuzzy/stream（SSE / WebSocket 实时推送）
+		public.GET("/query/fuzzy/stream", h.StreamFuzzyQueryResult)
 	}
-	public.POST("/login", handle.LoginHandler) // 登录接口（生成 JWT）
-	public.GET("/health", handle.HealthCheck)  // 健康检查接口
+	public.POST("/register", h.RegisterHandler)        // 注册接口
+	public.POST("/login", h.LoginHandler)              // 登录接口（生成 JWT）
+	public.POST("/refresh", h.RefreshTokenHandler)     // 刷新令牌接口
+	public.GET("/health", handle.HealthCheck)          // 健康检查接口
+	public.GET("/ready", h.ReadinessCheck)             // 就绪检查接口（优雅退出期间返回 503）
+	public.GET("/schema/hot", handle.HotSchemaHandler) // 数据结构 JSON Schema
 
 	// 需认证路由组（添加 JWT 中间件）
 	auth := r.Group("/api/auth")
-	auth.Use(until.JWTMiddleware()) // 所有子路由都需要 JWT 认证
+	auth.Use(until.JWTMiddleware(tokens)) // 所有子路由都需要 JWT 认证
 	{
-		auth.GET("/profile", handle.UserProfileHandler) // 获取用户信息
-		auth.POST("/operate", handle.OperateHandler)    // 示例业务接口
+		auth.GET("/profile", until.RequirePermission(until.PermProfileRead), h.UserProfileHandler)   // 获取用户信息
+		auth.POST("/operate", until.RequirePermission(until.PermDataOperate), handle.OperateHandler) // 示例业务接口
+		auth.POST("/logout", h.LogoutHandler)                                                        // 登出（吊销令牌）
+
+		// 关键词/数据源订阅提醒
+		auth.GET("/subscriptions", until.RequirePermission(until.PermSubscribe), h.ListSubscriptionsHandler)
+		auth.POST("/subscriptions", until.RequirePermission(until.PermSubscribe), h.CreateSubscriptionHandler)
+		auth.DELETE("/subscriptions/:id", until.RequirePermission(until.PermSubscribe), h.DeleteSubscriptionHandler)
+		auth.POST("/subscriptions/:id/verify", until.RequirePermission(until.PermSubscribe), h.VerifySubscriptionEmailHandler)
+
+		// 管理员路由（需 admin 角色）
+		admin := auth.Group("/admin", until.RequireRole(until.RoleAdmin))
+		admin.PUT("/users/:id/roles", until.RequirePermission(until.PermUserManage), h.UpdateUserRolesHandler) // 更新用户角色
+		admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))                                                  // 运行指标（expvar：MQ 发布结果、outbox 积压、订阅提醒等）
 	}
 }
 
 func main() {
 
 	mainCtx, cancel := context.WithCancel(context.Background())
-	defer func() {
-		cancel()
-		until.CloseMQ()
-		dbm.AllDbManger.Close()
-	}()
+	defer cancel()
 	var err error
 	config.InitTimeZone() // 初始化时区
 	// 初始化轮转日志（日志目录：./logs，前缀：crawler）
@@ -60,25 +87,132 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	dbm.AllDbManger, err = dbm.NewDbManger() //初始化数据库管理器
+	cfg := config.GetGlobalConfig()
+	applyLogLevel(cfg)
+	config.OnReload(applyLogLevel)
+	go config.Watch(mainCtx, 5*time.Second) // 监听配置文件变更（热更新 source_list、log_level、rate_limit）
+	if err := until.InitJWTKeys(cfg.JWT); err != nil {
+		panic(fmt.Sprintf("init jwt keys failed: %v", err))
+	}
+	dbManger, err := dbm.NewDbManger() //初始化数据库管理器
 	if err != nil {
 		panic(fmt.Sprintf("init db manger failed: %v", err))
 	}
-	until.InitMQ()
-	until.StartMQConsumers(mainCtx)
+	defer dbManger.Close()
+	if err := until.BootstrapAdmins(mainCtx, &dbManger.MongoManger); err != nil {
+		panic(fmt.Sprintf("bootstrap admin users failed: %v", err))
+	}
+	go dbManger.MaintainSearchIndexes(mainCtx, time.Minute) // 新数据源集合建索引、ngram 模式补齐存量文档
+	// 按 mq_backend 选择消息队列后端（RabbitMQ 连接断开后自动重连）
+	broker, err := until.NewBroker(cfg, dbManger.RedisManger)
+	if err != nil {
+		panic(fmt.Sprintf("create mq broker failed: %v", err))
+	}
+	if err := until.InitMQ(mainCtx, broker); err != nil {
+		panic(fmt.Sprintf("init mq failed: %v", err))
+	}
+	defer until.CloseMQ()
+	if err := until.StartMQConsumers(mainCtx, until.ConsumerDeps{
+		Fuzzy:  &dbManger.MongoManger,
+		Cache:  dbManger.RedisManger,
+		Events: dbManger.RedisManger,
+	}); err != nil {
+		panic(fmt.Sprintf("start mq consumers failed: %v", err))
+	}
+
+	// 订阅提醒：检查新批次并通过邮件/Webhook 通知
+	if cfg.Notify.Enabled {
+		go until.RunSubscriptionMatcher(mainCtx, until.MatcherDeps{
+			HotData:   dbManger.RedisManger,
+			Subs:      &dbManger.MongoManger,
+			Locker:    dbManger.RedisManger,
+			Notifiers: until.NewNotifiers(cfg),
+		})
+	}
+
+	// 注入存储实现
+	var mailer until.Mailer
+	if len(cfg.EmailList) > 0 {
+		mailer = until.NewSMTPNotifier(cfg.EmailList)
+	}
+	h := handle.NewHandler(handle.Deps{
+		HotData:   dbManger.RedisManger,
+		Fuzzy:     &dbManger.MongoManger,
+		Trend:     &dbManger.MongoManger,
+		Cache:     dbManger.RedisManger,
+		Locker:    dbManger.RedisManger,
+		Users:     &dbManger.MongoManger,
+		Subs:      &dbManger.MongoManger,
+		Tokens:    dbManger.RedisManger,
+		Events:    dbManger.RedisManger,
+		Publisher: until.MQPublisher{},
+		Mailer:    mailer,
+	})
 
 	gin.SetMode(gin.DebugMode)
 	r := gin.Default()
+	// 限流按客户端 IP 计数，只信任配置的代理转发的 X-Forwarded-For，避免客户端伪造该头绕过限流
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("set trusted proxies failed: %v", err))
+	}
 
 	r.Use(until.ErrorAndLogHandler())
 
 	// 注册路由
-	RegisterRoutes(r)
+	RegisterRoutes(r, h, dbManger.RedisManger)
 
 	// 启动服务
-	fmt.Println("服务启动成功：http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
+	srv := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	h.SetReady(true)
+	fmt.Println("服务启动成功：" + cfg.ListenAddr)
+
+	// 等待退出信号
+	signalCtx, stopSignal := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignal()
+	select {
+	case <-signalCtx.Done():
+		slog.Info("收到退出信号，开始优雅退出")
+	case err := <-serveErr:
+		// 尚未对外提供服务，直接退出（panic 仍会执行 defer 关闭连接）
 		panic(fmt.Sprintf("服务启动失败：%v", err))
 	}
+	gracefulShutdown(srv, h, cancel)
+	// 返回后依次执行 defer：关闭 MQ、数据库连接
+}
 
+// gracefulShutdown 优雅退出：标记未就绪 → 等待流量摘除 → 结束 SSE/WebSocket 长连接 → 停止 HTTP 服务并等待处理中的请求 →
+// 投递剩余访问日志 → 停止消费者并等待处理中的消息确认，整体不超过 shutdown_timeout
+func gracefulShutdown(srv *http.Server, h *handle.Handler, stopConsumers context.CancelFunc) {
+	cfg := config.GetGlobalConfig()
+	h.SetReady(false)
+	time.Sleep(cfg.ShutdownDelay.Duration)
+	h.CloseStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("HTTP 服务关闭超时", "error", err)
+	}
+	if err := until.FlushAccessLogs(ctx); err != nil {
+		slog.Error("访问日志投递未完成", "error", err)
+	}
+	stopConsumers()
+	if err := until.WaitMQConsumers(ctx); err != nil {
+		slog.Error("MQ 消费者未在限期内退出，未确认消息将由 RabbitMQ 重新投递", "error", err)
+	}
+	slog.Info("优雅退出完成")
+}
+
+// applyLogLevel 按配置设置日志级别（启动及热更新时调用）
+func applyLogLevel(cfg config.GlobalConfig) {
+	level, err := config.ParseLogLevel(cfg.LogLevel)
+	if err != nil {
+		slog.Error("invalid log level", "error", err)
+		return
+	}
+	mylog.SetLevel(level)
 }
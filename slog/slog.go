@@ -73,6 +73,21 @@ func (h multiHandler) WithGroup(name string) slog.Handler {
 	return multiHandler(newHandlers)
 }
 
+// logLevel 全局日志级别（可运行时调整，控制台直接使用）
+var logLevel = new(slog.LevelVar)
+
+// fileLevel 文件日志级别：不低于 Info，且不低于全局级别
+type fileLevel struct{}
+
+func (fileLevel) Level() slog.Level {
+	return max(slog.LevelInfo, logLevel.Level())
+}
+
+// SetLevel 运行时调整日志级别（配置热更新时调用）
+func SetLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
 // RotatingFileWriter 按日期轮转的文件 Writer
 type RotatingFileWriter struct {
 	logDir      string   // 日志目录
@@ -112,8 +127,9 @@ func InitRotatingLogger(logDir, prefix string) (*RotatingFileWriter, error) {
 	targetTimeFormat := "2006/01/02 15:04:05"
 
 	// 1. 控制台 Handler：文本格式 + 时间格式化
+	logLevel.Set(slog.LevelDebug)
 	consoleHandler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
+		Level: logLevel,
 		// 自定义文本格式的时间输出（仅 TextHandler 支持）
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			// 替换 "time" 字段的格式
@@ -127,7 +143,7 @@ func InitRotatingLogger(logDir, prefix string) (*RotatingFileWriter, error) {
 			return a
 		},
 	})
-	fileHandler := slog.NewJSONHandler(rotatingWriter, &slog.HandlerOptions{Level: slog.LevelInfo})
+	fileHandler := slog.NewJSONHandler(rotatingWriter, &slog.HandlerOptions{Level: fileLevel{}})
 	multiHandler := NewMultiHandler(consoleHandler, fileHandler)
 
 	// 5. （可选）添加全局属性（所有日志都会包含该属性）
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(Duration{})
+
+// applyEnv 用环境变量覆盖配置，变量名为 前缀_JSON字段名（大写，嵌套结构用 _ 连接）
+// 例如 GOWEB_REDIS_PASSWORD、GOWEB_RATE_LIMIT_BURST、GOWEB_JWT_ACCESS_TOKEN_TTL
+// 字符串切片用逗号分隔，其他复杂类型（如 jwt.keys）按 JSON 解析
+func applyEnv(cfg *GlobalConfig, prefix string) error {
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), prefix)
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		envName := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			if err := applyEnvStruct(fv, envName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, raw); err != nil {
+			return fmt.Errorf("invalid env %s=%q: %w", envName, raw, err)
+		}
+	}
+	return nil
+}
+
+// setFromEnv 按字段类型解析环境变量值
+func setFromEnv(fv reflect.Value, raw string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(Duration{d}))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64, reflect.Float32:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.String {
+			var items []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			fv.Set(reflect.ValueOf(items))
+			return nil
+		}
+		return json.Unmarshal([]byte(raw), fv.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(raw), fv.Addr().Interface())
+	}
+	return nil
+}
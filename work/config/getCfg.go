@@ -2,57 +2,442 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 var (
 	ConfigPath string
-	ConfigMu   sync.Mutex //protect update with config.json
+	ConfigMu   sync.RWMutex //protect update with config.json
 )
 
+// EnvPrefix 环境变量前缀，如 GOWEB_MONGO_URL、GOWEB_RATE_LIMIT_BURST
+const EnvPrefix = "GOWEB"
+
 // GlobalConfig 全局配置结构体
+// 加载顺序：默认值 → config.json → GOWEB_* 环境变量，后者覆盖前者
 type GlobalConfig struct {
-	MongoURL         string   `json:"mongo_url"`          //链接
-	MongoDBNameData  string   `json:"mongodb_name_data"`  //数据库名
-	MongoDBNameUsers string   `json:"mongodb_name_users"` //用户数据库名
-	RedisSentinelArr []string `json:"redis_sentinelArr"`  // Redis 哨兵地址列表
-	SourceList       []string `json:"source_list"`        // 数据源列表
+	ListenAddr string `json:"listen_addr"` // HTTP 监听地址
+	LogLevel   string `json:"log_level"`   // 日志级别 debug/info/warn/error（可热更新）
+	// 收到退出信号后先标记未就绪，等待 shutdown_delay 让负载均衡摘除流量，
+	// 再在 shutdown_timeout 内完成处理中的请求、消费者确认和日志投递
+	ShutdownDelay   Duration `json:"shutdown_delay"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// 可信反向代理的 IP/CIDR，只有来自这些地址的请求才采用 X-Forwarded-For 作为客户端 IP（限流、访问日志），为空表示不信任任何代理
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	MongoURL         string `json:"mongo_url"`          //链接
+	MongoDBNameData  string `json:"mongodb_name_data"`  //数据库名
+	MongoDBNameUsers string `json:"mongodb_name_users"` //用户数据库名
+
+	RedisMode         string          `json:"redis_mode"`           // 部署模式 standalone/sentinel/cluster
+	RedisAddrs        []string        `json:"redis_addrs"`          // 单节点地址（取第一个）或集群种子节点列表
+	RedisSentinelArr  []string        `json:"redis_sentinelArr"`    // Redis 哨兵地址列表（sentinel 模式）
+	RedisMasterName   string          `json:"redis_master_name"`    // 哨兵监控的主节点名称
+	RedisPassword     string          `json:"redis_password"`       // Redis 节点密码
+	RedisDB           int             `json:"redis_db"`             // 数据库索引
+	RedisPoolSize     int             `json:"redis_pool_size"`      // 最大连接数
+	RedisMinIdleConns int             `json:"redis_min_idle_conns"` // 最小空闲连接数
+	RedisMaxBatches   int             `json:"redis_max_batches"`    // 每个 source 保留的最大数据批次数
+	RedisRead         RedisReadConfig `json:"redis_read"`           // 从节点读路由与熔断（sentinel 模式）
+	// 哨兵返回的节点地址 → 实际可拨号地址（如 Docker 内网 IP 映射到宿主机端口），未命中的地址原样使用
+	RedisAddrMap map[string]string `json:"redis_addr_map"`
+
+	MQBackend             string            `json:"mq_backend"`              // 消息队列后端：rabbitmq/redis_streams/memory
+	RabbitMQURL           string            `json:"rabbitmq_url"`            // RabbitMQ 连接地址（mq_backend=rabbitmq 时必填）
+	FuzzyQueryConcurrency int               `json:"fuzzy_query_concurrency"` // 模糊查询消费者并发数
+	MQPublish             MQPublishConfig   `json:"mq_publish"`              // 发布确认与本地 outbox
+	FuzzyRetry            RetryConfig       `json:"fuzzy_retry"`             // 模糊查询失败重试
+	FuzzySearch           FuzzySearchConfig `json:"fuzzy_search"`            // 模糊检索策略与索引
+
+	SourceList []string        `json:"source_list"` // 数据源列表（可热更新）
+	RateLimit  RateLimitConfig `json:"rate_limit"`  // 公开接口限流（可热更新）
+	JWT        JWTConfig       `json:"jwt"`         // JWT 签名配置
+	// 初始管理员用户名：服务启动时为其中已注册的账号授予 admin 角色（用于新部署创建首个管理员，先注册再重启生效）
+	// 列表中的用户不能通过角色接口撤销 admin，需先从配置中移除
+	AdminUsers []string `json:"admin_users"`
+
+	EmailList []EmailAccount `json:"email_list"` // 发送订阅提醒的 SMTP 账号（轮流使用，失败时换下一个）
+	Notify    NotifyConfig   `json:"notify"`     // 关键词订阅提醒
+}
+
+// EmailAccount SMTP 发信账号，auth_code 为邮箱服务商的授权码
+// smtp_host 默认为 smtp.<邮箱域名>，smtp_port 默认 465（SSL 直连），其他端口在服务器支持时使用 STARTTLS
+type EmailAccount struct {
+	Email    string `json:"email"`
+	AuthCode string `json:"auth_code"`
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+}
+
+// NotifyConfig 订阅匹配与提醒配置
+// 每 check_interval 检查一次各数据源的最新批次；同一订阅的同一条目在 dedupe_ttl 内只提醒一次，
+// 同一订阅两次提醒至少间隔 min_interval（期间命中的条目在下次提醒中合并发送）
+type NotifyConfig struct {
+	Enabled          bool     `json:"enabled"`
+	CheckInterval    Duration `json:"check_interval"`
+	DedupeTTL        Duration `json:"dedupe_ttl"`
+	MinInterval      Duration `json:"min_interval"`
+	SendTimeout      Duration `json:"send_timeout"`      // 单次发送（SMTP/Webhook）超时
+	MaxSubscriptions int      `json:"max_subscriptions"` // 每个用户最多的订阅数
+}
+
+// MQPublishConfig MQ 发布确认与 outbox 配置
+// 未在 confirm_timeout 内得到 broker 确认的消息写入 outbox_dir，每 outbox_retry_interval 按序重试
+// 已连接 broker 时仍失败 outbox_max_attempts 次的消息移入 outbox_dir/dead，不再阻塞后续消息
+type MQPublishConfig struct {
+	ConfirmTimeout      Duration `json:"confirm_timeout"`
+	OutboxDir           string   `json:"outbox_dir"`
+	OutboxMaxMessages   int      `json:"outbox_max_messages"` // 超出后新消息发布失败
+	OutboxRetryInterval Duration `json:"outbox_retry_interval"`
+	OutboxMaxAttempts   int      `json:"outbox_max_attempts"`
+}
+
+// RetryConfig 消费失败重试配置：最多处理 max_attempts 次（含首次），每次重试间隔 delay，之后进入死信队列
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts"`
+	Delay       Duration `json:"delay"`
+}
+
+// 模糊检索策略
+const (
+	SearchStrategyRegex = "regex" // 转义后的字面量正则（默认，无需额外字段，大集合较慢）
+	SearchStrategyText  = "text"  // $text 文本索引，按相关度排序（按空格/标点分词，不适合无空格的中文）
+	SearchStrategyNgram = "ngram" // n-gram 多键索引（适合中文标题，启动后为存量文档补齐 n-gram 字段）
+)
+
+// FuzzySearchConfig 模糊检索配置，启动时按策略创建所需索引
+type FuzzySearchConfig struct {
+	Strategy  string `json:"strategy"`   // regex/text/ngram
+	NgramSize int    `json:"ngram_size"` // ngram 模式切分长度（中文建议 2）
+}
+
+// Redis 部署模式
+const (
+	RedisModeStandalone = "standalone" // 单节点
+	RedisModeSentinel   = "sentinel"   // 哨兵 + 只读从节点
+	RedisModeCluster    = "cluster"    // Redis Cluster
+)
+
+// RedisReadConfig 从节点读路由策略
+type RedisReadConfig struct {
+	Policy           string   `json:"policy"`            // random/round_robin/least_latency/lag_bounded/master_only
+	MaxLagBytes      int64    `json:"max_lag_bytes"`     // lag_bounded 允许的最大复制偏移差
+	ProbeInterval    Duration `json:"probe_interval"`    // 延迟/复制偏移探测间隔
+	FailureThreshold int      `json:"failure_threshold"` // 连续失败多少次后熔断
+	OpenDuration     Duration `json:"open_duration"`     // 熔断持续时间
+}
+
+// RateLimitConfig 按客户端 IP 的令牌桶限流配置
+type RateLimitConfig struct {
+	Enabled           bool    `json:"enabled"`
+	RequestsPerSecond float64 `json:"requests_per_second"` // 令牌生成速率
+	Burst             int     `json:"burst"`               // 桶容量
+}
+
+// JWTConfig JWT 非对称签名配置（启动时加载，轮换密钥需修改配置后重启）
+// 仓库自带的 config.json 未配置 keys、开启 allow_ephemeral，可直接本地启动；生产部署需配置 keys 并关闭 allow_ephemeral
+type JWTConfig struct {
+	ActiveKid       string         `json:"active_kid"`        // 当前用于签名的密钥 kid
+	Keys            []JWTKeyConfig `json:"keys"`              // 全部密钥（含仅用于验签的旧密钥）
+	AccessTokenTTL  Duration       `json:"access_token_ttl"`  // 访问令牌有效期，如 "15m"
+	RefreshTokenTTL Duration       `json:"refresh_token_ttl"` // 刷新令牌有效期，如 "168h"
+	// 未配置 keys 时是否允许使用进程内临时密钥（仅限本地开发：重启后令牌失效，多实例之间互不认可）
+	AllowEphemeral bool `json:"allow_ephemeral"`
+}
+
+// Host SMTP 服务器地址（未配置时按邮箱域名推断）
+func (a EmailAccount) Host() string {
+	if a.SMTPHost != "" {
+		return a.SMTPHost
+	}
+	return "smtp." + a.Email[strings.LastIndex(a.Email, "@")+1:]
+}
+
+// Port SMTP 端口（默认 465）
+func (a EmailAccount) Port() int {
+	if a.SMTPPort == 0 {
+		return 465
+	}
+	return a.SMTPPort
+}
+
+// JWTKeyConfig 单个签名密钥，私钥/公钥可用文件路径或内联 PEM 提供
+// 只配置公钥的密钥仅用于验签（轮换期间保留旧密钥）
+type JWTKeyConfig struct {
+	Kid            string `json:"kid"`
+	PrivateKeyFile string `json:"private_key_file"`
+	PrivateKey     string `json:"private_key"` // 内联 PEM
+	PublicKeyFile  string `json:"public_key_file"`
+	PublicKey      string `json:"public_key"` // 内联 PEM
+}
+
+// Duration 支持 "15m"、"24h" 形式的 JSON 时长
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
 
+// defaultConfig 默认值（未在配置文件或环境变量中设置时生效）
+func defaultConfig() GlobalConfig {
+	return GlobalConfig{
+		ListenAddr:            ":8080",
+		LogLevel:              "debug",
+		ShutdownDelay:         Duration{2 * time.Second},
+		ShutdownTimeout:       Duration{15 * time.Second},
+		RedisMode:             RedisModeSentinel,
+		RedisMasterName:       "mymaster",
+		RedisPoolSize:         100,
+		RedisMinIdleConns:     10,
+		RedisMaxBatches:       6,
+		MQBackend:             "rabbitmq",
+		FuzzyQueryConcurrency: 8,
+		MQPublish: MQPublishConfig{
+			ConfirmTimeout:      Duration{5 * time.Second},
+			OutboxDir:           "./outbox",
+			OutboxMaxMessages:   10000,
+			OutboxRetryInterval: Duration{2 * time.Second},
+			OutboxMaxAttempts:   10,
+		},
+		FuzzyRetry: RetryConfig{
+			MaxAttempts: 3,
+			Delay:       Duration{5 * time.Second},
+		},
+		FuzzySearch: FuzzySearchConfig{
+			Strategy:  SearchStrategyRegex,
+			NgramSize: 2,
+		},
+		RedisRead: RedisReadConfig{
+			Policy:           "random",
+			MaxLagBytes:      1024,
+			ProbeInterval:    Duration{2 * time.Second},
+			FailureThreshold: 3,
+			OpenDuration:     Duration{30 * time.Second},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           false,
+			RequestsPerSecond: 20,
+			Burst:             40,
+		},
+		JWT: JWTConfig{
+			AccessTokenTTL:  Duration{15 * time.Minute},
+			RefreshTokenTTL: Duration{7 * 24 * time.Hour},
+		},
+		Notify: NotifyConfig{
+			Enabled:          false,
+			CheckInterval:    Duration{time.Minute},
+			DedupeTTL:        Duration{24 * time.Hour},
+			MinInterval:      Duration{10 * time.Minute},
+			SendTimeout:      Duration{10 * time.Second},
+			MaxSubscriptions: 20,
+		},
+	}
 }
 
 var globalConfig GlobalConfig
 
-// Init 初始化配置（读取config.json）
+// Init 初始化配置（默认值 + config.json + 环境变量），校验失败时返回全部错误
 func Init(configPath string) error {
+	cfg, err := load(configPath)
+	if err != nil {
+		return err
+	}
+
+	ConfigMu.Lock()
 	ConfigPath = configPath
+	globalConfig = cfg
+	ConfigMu.Unlock()
+	return nil
+}
+
+// load 按层加载并校验配置
+func load(configPath string) (GlobalConfig, error) {
+	cfg := defaultConfig()
+
 	// 处理配置文件路径（支持相对路径）
 	absPath, err := filepath.Abs(configPath)
 	if err != nil {
-		return err
+		return cfg, err
 	}
 
 	// 读取配置文件
 	file, err := os.Open(absPath)
 	if err != nil {
-		return err
+		return cfg, err
 	}
 	defer file.Close()
 
-	// 解析JSON
+	// 解析JSON（覆盖默认值）
 	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&globalConfig); err != nil {
-		return err
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse config file %s failed: %w", absPath, err)
 	}
 
-	return nil
+	// 环境变量覆盖
+	if err := applyEnv(&cfg, EnvPrefix); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate 校验必填项和取值范围
+func (c *GlobalConfig) Validate() error {
+	var errs []error
+	required := []struct{ name, value string }{
+		{"listen_addr", c.ListenAddr},
+		{"mongo_url", c.MongoURL},
+		{"mongodb_name_data", c.MongoDBNameData},
+		{"mongodb_name_users", c.MongoDBNameUsers},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", field.name))
+		}
+	}
+	switch c.RedisMode {
+	case RedisModeSentinel:
+		if len(c.RedisSentinelArr) == 0 {
+			errs = append(errs, errors.New("redis_sentinelArr must not be empty in sentinel mode"))
+		}
+		if strings.TrimSpace(c.RedisMasterName) == "" {
+			errs = append(errs, errors.New("redis_master_name is required in sentinel mode"))
+		}
+	case RedisModeStandalone, RedisModeCluster:
+		if len(c.RedisAddrs) == 0 {
+			errs = append(errs, fmt.Errorf("redis_addrs must not be empty in %s mode", c.RedisMode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("redis_mode %q is invalid (standalone/sentinel/cluster)", c.RedisMode))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("trusted_proxies: %q is not an IP or CIDR", proxy))
+		}
+	}
+	if _, err := ParseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.RedisRead.Policy {
+	case "random", "round_robin", "least_latency", "lag_bounded", "master_only":
+	default:
+		errs = append(errs, fmt.Errorf("redis_read.policy %q is invalid", c.RedisRead.Policy))
+	}
+	if c.RedisRead.ProbeInterval.Duration <= 0 || c.RedisRead.OpenDuration.Duration <= 0 || c.RedisRead.FailureThreshold <= 0 {
+		errs = append(errs, errors.New("redis_read.probe_interval, open_duration and failure_threshold must be > 0"))
+	}
+	if c.ShutdownDelay.Duration < 0 || c.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("shutdown_delay must be >= 0 and shutdown_timeout must be > 0"))
+	}
+	if c.RedisPoolSize <= 0 {
+		errs = append(errs, errors.New("redis_pool_size must be > 0"))
+	}
+	if c.RedisMinIdleConns < 0 || c.RedisMinIdleConns > c.RedisPoolSize {
+		errs = append(errs, errors.New("redis_min_idle_conns must be between 0 and redis_pool_size"))
+	}
+	if c.RedisMaxBatches <= 0 {
+		errs = append(errs, errors.New("redis_max_batches must be > 0"))
+	}
+	switch c.MQBackend {
+	case "rabbitmq":
+		if strings.TrimSpace(c.RabbitMQURL) == "" {
+			errs = append(errs, errors.New("rabbitmq_url is required when mq_backend is rabbitmq"))
+		}
+	case "redis_streams", "memory":
+	default:
+		errs = append(errs, fmt.Errorf("mq_backend %q is invalid (rabbitmq/redis_streams/memory)", c.MQBackend))
+	}
+	if c.FuzzyQueryConcurrency <= 0 {
+		errs = append(errs, errors.New("fuzzy_query_concurrency must be > 0"))
+	}
+	if c.MQPublish.ConfirmTimeout.Duration <= 0 || c.MQPublish.OutboxRetryInterval.Duration <= 0 || c.MQPublish.OutboxMaxMessages <= 0 || c.MQPublish.OutboxMaxAttempts <= 0 {
+		errs = append(errs, errors.New("mq_publish.confirm_timeout, outbox_retry_interval, outbox_max_messages and outbox_max_attempts must be > 0"))
+	}
+	if strings.TrimSpace(c.MQPublish.OutboxDir) == "" {
+		errs = append(errs, errors.New("mq_publish.outbox_dir is required"))
+	}
+	if c.FuzzyRetry.MaxAttempts <= 0 || c.FuzzyRetry.Delay.Duration <= 0 {
+		errs = append(errs, errors.New("fuzzy_retry.max_attempts and fuzzy_retry.delay must be > 0"))
+	}
+	switch c.FuzzySearch.Strategy {
+	case SearchStrategyRegex, SearchStrategyText:
+	case SearchStrategyNgram:
+		if c.FuzzySearch.NgramSize < 1 || c.FuzzySearch.NgramSize > 4 {
+			errs = append(errs, errors.New("fuzzy_search.ngram_size must be between 1 and 4"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("fuzzy_search.strategy %q is invalid (regex/text/ngram)", c.FuzzySearch.Strategy))
+	}
+	if c.RateLimit.Enabled && (c.RateLimit.RequestsPerSecond <= 0 || c.RateLimit.Burst <= 0) {
+		errs = append(errs, errors.New("rate_limit.requests_per_second and rate_limit.burst must be > 0 when enabled"))
+	}
+	if c.JWT.AccessTokenTTL.Duration <= 0 || c.JWT.RefreshTokenTTL.Duration <= 0 {
+		errs = append(errs, errors.New("jwt.access_token_ttl and jwt.refresh_token_ttl must be > 0"))
+	}
+	if len(c.JWT.Keys) == 0 && !c.JWT.AllowEphemeral {
+		errs = append(errs, errors.New("jwt.keys must not be empty (set jwt.allow_ephemeral to use a temporary key for local development)"))
+	}
+	if len(c.JWT.Keys) > 0 && c.JWT.ActiveKid == "" {
+		errs = append(errs, errors.New("jwt.active_kid is required when jwt.keys is set"))
+	}
+	for i, account := range c.EmailList {
+		if !strings.Contains(account.Email, "@") || account.AuthCode == "" {
+			errs = append(errs, fmt.Errorf("email_list[%d]: email and auth_code are required", i))
+		}
+		if account.SMTPPort < 0 || account.SMTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("email_list[%d]: smtp_port %d is invalid", i, account.SMTPPort))
+		}
+	}
+	if c.Notify.Enabled {
+		if c.Notify.CheckInterval.Duration <= 0 || c.Notify.DedupeTTL.Duration <= 0 || c.Notify.SendTimeout.Duration <= 0 {
+			errs = append(errs, errors.New("notify.check_interval, dedupe_ttl and send_timeout must be > 0 when enabled"))
+		}
+		if c.Notify.MinInterval.Duration < 0 || c.Notify.MaxSubscriptions <= 0 {
+			errs = append(errs, errors.New("notify.min_interval must be >= 0 and notify.max_subscriptions must be > 0"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseLogLevel 解析日志级别字符串
+func ParseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("log_level %q is invalid (debug/info/warn/error)", level)
+	}
+	return l, nil
 }
 
-// GetGlobalConfig 获取全局配置
+// GetGlobalConfig 获取全局配置（返回快照，热更新后再次调用可获得新值）
 func GetGlobalConfig() GlobalConfig {
+	ConfigMu.RLock()
+	defer ConfigMu.RUnlock()
 	return globalConfig
 }
 
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+)
+
+var reloadHooks []func(GlobalConfig)
+
+// OnReload 注册热更新回调（在 Watch 启动前调用），回调参数为更新后的配置
+func OnReload(fn func(GlobalConfig)) {
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Watch 定期检查配置文件修改时间，变化时重新加载可热更新的配置项
+// 可热更新：source_list、log_level、rate_limit；其余配置（连接地址、连接池等）需重启生效
+func Watch(ctx context.Context, interval time.Duration) {
+	ConfigMu.RLock()
+	path := ConfigPath
+	ConfigMu.RUnlock()
+
+	lastMod := fileModTime(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime := fileModTime(path)
+			if modTime.IsZero() || modTime.Equal(lastMod) {
+				continue
+			}
+			lastMod = modTime
+			reload(path)
+		}
+	}
+}
+
+// reload 重新加载配置，仅应用可热更新的字段；加载或校验失败时保留旧配置
+func reload(path string) {
+	loaded, err := load(path)
+	if err != nil {
+		slog.Error("配置热更新失败，保留当前配置", "path", path, "error", err)
+		return
+	}
+
+	ConfigMu.Lock()
+	current := globalConfig
+	if !reflect.DeepEqual(structuralPart(current), structuralPart(loaded)) {
+		slog.Warn("检测到结构性配置变更，需重启后生效", "path", path)
+	}
+	current.SourceList = loaded.SourceList
+	current.LogLevel = loaded.LogLevel
+	current.RateLimit = loaded.RateLimit
+	globalConfig = current
+	ConfigMu.Unlock()
+
+	slog.Info("配置热更新成功", "source_list", current.SourceList, "log_level", current.LogLevel, "rate_limit", current.RateLimit)
+	for _, hook := range reloadHooks {
+		hook(current)
+	}
+}
+
+// structuralPart 去掉可热更新字段后的配置，用于判断是否存在需重启的变更
+func structuralPart(cfg GlobalConfig) GlobalConfig {
+	cfg.SourceList = nil
+	cfg.LogLevel = ""
+	cfg.RateLimit = RateLimitConfig{}
+	return cfg
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
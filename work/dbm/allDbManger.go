@@ -18,14 +18,9 @@ type DbManger struct {
 	*RedisManger
 }
 
-var (
-	AllDbManger *DbManger
-)
-
 func NewDbManger() (*DbManger, error) {
 	cfg := config.GetGlobalConfig()
 	mongoUrl := cfg.MongoURL
-	redisSentinelArr := cfg.RedisSentinelArr
 	var mongoClient *mongo.Client
 
 	mongoCli, err := mongo.Connect(context.Background(), options.Client().ApplyURI(mongoUrl))
@@ -38,49 +33,105 @@ func NewDbManger() (*DbManger, error) {
 
 	mongoClient = mongoCli
 
-	sentinelOpts := &redis.FailoverOptions{
-		MasterName:    "mymaster",       // 哨兵监控的主节点名称（必须与哨兵配置一致）
-		SentinelAddrs: redisSentinelArr, // 哨兵节点地址列表
-		Password:      "123456",         // Redis 节点密码（与集群配置一致）
-		DB:            0,                // 默认数据库索引
-		// 连接池配置（按需调整，优化性能）
-		PoolSize:     100, // 最大连接数（默认：CPU 核心数 * 10）
-		MinIdleConns: 10,  // 最小空闲连接数（避免频繁创建连接）
-		// 超时配置（避免卡死）
-		DialTimeout:  5 * time.Second, // 连接超时
-		ReadTimeout:  3 * time.Second, // 读超时
-		WriteTimeout: 3 * time.Second, // 写超时
-		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			// addr 是哨兵返回的主节点地址（如 172.28.0.10:6379）
-			// 根据主节点容器内 IP，映射到对应的主机端口
-			switch addr {
-			case "172.28.0.10:6379": // 原主节点 → 主机端口 6379
-				addr = "localhost:6379"
-			case "172.28.0.11:6379": // 从节点1 → 主机端口 6380
-				addr = "localhost:6380"
-			case "172.28.0.12:6379": // 从节点2 → 主机端口 6381
-				addr = "localhost:6381"
-			}
-			// 用替换后的地址拨号连接
-			return net.DialTimeout(network, addr, 5*time.Second)
-		},
+	redisManage, err := newRedisManager(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init redis manager failed: %w", err)
 	}
 
-	redisManage, err := NewRedisManager(sentinelOpts, 6)
+	search, err := NewSearchStrategy(cfg.FuzzySearch)
 	if err != nil {
-		return nil, fmt.Errorf("init redis manager failed: %w", err)
+		return nil, fmt.Errorf("init fuzzy search failed: %w", err)
+	}
+	mongoManage := MongoManger{mongoClient: mongoClient,
+		mongodbDatasName: cfg.MongoDBNameData,
+		mongodbUsersName: cfg.MongoDBNameUsers,
+		search:           search,
+	}
+	indexCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mongoManage.EnsureUserIndexes(indexCtx); err != nil {
+		return nil, fmt.Errorf("init user store failed: %w", err)
+	}
+	if err := mongoManage.EnsureSubscriptionIndexes(indexCtx); err != nil {
+		return nil, fmt.Errorf("init subscription store failed: %w", err)
+	}
+	// 检索索引（已存在的集合较大时创建耗时较长）
+	searchIndexCtx, cancelSearch := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancelSearch()
+	if err := mongoManage.EnsureSearchIndexes(searchIndexCtx); err != nil {
+		return nil, fmt.Errorf("create fuzzy search indexes failed: %w", err)
 	}
 
 	return &DbManger{
-		MongoManger: MongoManger{mongoClient: mongoClient,
-			mongodbDatasName: cfg.MongoDBNameData,
-			mongodbUsersName: cfg.MongoDBNameUsers,
-		},
+		MongoManger: mongoManage,
 		RedisManger: redisManage,
 	}, nil
 
 }
 
+// newRedisManager 按 redis_mode 创建 Redis 管理器（单节点 / 哨兵 / 集群）
+func newRedisManager(cfg config.GlobalConfig) (*RedisManger, error) {
+	translate := NewAddrMapTranslator(cfg.RedisAddrMap)
+	// addr 是哨兵或集群返回的节点地址，按配置映射为可访问地址后拨号
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := net.Dialer{Timeout: 5 * time.Second}
+		return d.DialContext(ctx, network, translate(addr))
+	}
+
+	switch cfg.RedisMode {
+	case config.RedisModeStandalone:
+		return NewStandaloneRedisManager(&redis.Options{
+			Addr:         cfg.RedisAddrs[0],
+			Password:     cfg.RedisPassword,
+			DB:           cfg.RedisDB,
+			PoolSize:     cfg.RedisPoolSize,
+			MinIdleConns: cfg.RedisMinIdleConns,
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+			Dialer:       dialer,
+		}, cfg.RedisMaxBatches)
+
+	case config.RedisModeCluster:
+		return NewClusterRedisManager(&redis.ClusterOptions{
+			Addrs:         cfg.RedisAddrs,
+			Password:      cfg.RedisPassword,
+			PoolSize:      cfg.RedisPoolSize,
+			MinIdleConns:  cfg.RedisMinIdleConns,
+			ReadOnly:      true, // 只读命令路由到从节点
+			RouteRandomly: true, // 在主从节点间随机分摊读请求
+			DialTimeout:   5 * time.Second,
+			ReadTimeout:   3 * time.Second,
+			WriteTimeout:  3 * time.Second,
+			Dialer:        dialer,
+		}, cfg.RedisMaxBatches)
+
+	default:
+		sentinelOpts := &redis.FailoverOptions{
+			MasterName:    cfg.RedisMasterName,  // 哨兵监控的主节点名称（必须与哨兵配置一致）
+			SentinelAddrs: cfg.RedisSentinelArr, // 哨兵节点地址列表
+			Password:      cfg.RedisPassword,    // Redis 节点密码（与集群配置一致）
+			DB:            cfg.RedisDB,          // 数据库索引
+			// 连接池配置（按需调整，优化性能）
+			PoolSize:     cfg.RedisPoolSize,     // 最大连接数
+			MinIdleConns: cfg.RedisMinIdleConns, // 最小空闲连接数（避免频繁创建连接）
+			// 超时配置（避免卡死）
+			DialTimeout:  5 * time.Second, // 连接超时
+			ReadTimeout:  3 * time.Second, // 读超时
+			WriteTimeout: 3 * time.Second, // 写超时
+			Dialer:       dialer,
+		}
+		readOpts := ReadOptions{
+			Policy:           cfg.RedisRead.Policy,
+			MaxLagBytes:      cfg.RedisRead.MaxLagBytes,
+			ProbeInterval:    cfg.RedisRead.ProbeInterval.Duration,
+			FailureThreshold: cfg.RedisRead.FailureThreshold,
+			OpenDuration:     cfg.RedisRead.OpenDuration.Duration,
+		}
+		return NewRedisManager(sentinelOpts, cfg.RedisMaxBatches, translate, readOpts)
+	}
+}
+
 func (Db *DbManger) Close() {
 
 	var err error
@@ -88,15 +139,6 @@ func (Db *DbManger) Close() {
 	if err != nil {
 		slog.Error("mongodbConnect clsoe fail", "error", err)
 	}
-	for _, cli := range Db.RedisManger.slaveClients {
-		err = cli.Close()
-		if err != nil {
-			slog.Error("redisSlaves  clsoe fail", "error", err)
-		}
-	}
-	err = Db.RedisManger.masterClient.Close()
-	if err != nil {
-		slog.Error("redisMaster clsoe fail", "error", err)
-	}
+	Db.RedisManger.Close()
 
 }
@@ -0,0 +1,44 @@
+package dbm
+
+import (
+	"context"
+	"fmt"
+)
+
+// PublishEvent 通过主节点 PUBLISH 发布事件
+func (r *RedisManger) PublishEvent(ctx context.Context, channel string, payload []byte) error {
+	return r.masterClient.Publish(ctx, channel, payload).Err()
+}
+
+// SubscribeEvents 订阅频道（主节点），确认订阅生效后返回，ctx 取消后退订
+func (r *RedisManger) SubscribeEvents(ctx context.Context, channel string) (<-chan []byte, error) {
+	pubsub := r.masterClient.Subscribe(ctx, channel)
+	// 等待订阅确认，保证返回后发布的事件不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("subscribe %s failed: %w", channel, err)
+	}
+
+	out := make(chan []byte, 16)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
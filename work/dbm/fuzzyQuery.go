@@ -0,0 +1,220 @@
+package dbm
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 模糊检索排序方式
+const (
+	FuzzySortTime      = "time"      // 爬取时间倒序（默认）
+	FuzzySortRelevance = "relevance" // 相关度倒序（text 策略用 textScore，其他策略按标题匹配程度估算）
+	FuzzySortHot       = "hot"       // 热度倒序
+)
+
+// MaxFuzzyResults 单个查询最多返回（并缓存）的结果数，分页在缓存的结果上进行
+const MaxFuzzyResults = 500
+
+// FuzzyQuery 模糊检索条件
+type FuzzyQuery struct {
+	Keyword string    `json:"keyword"`
+	Sources []string  `json:"sources,omitempty"` // 数据源（即集合名），为空表示全部
+	From    time.Time `json:"from"`              // 爬取时间下限，零值表示不限
+	To      time.Time `json:"to"`                // 爬取时间上限，零值表示不限
+	Sort    string    `json:"sort"`
+}
+
+// Normalize 规范化检索条件：关键词去首尾空白并合并连续空白，数据源小写去重排序，时间精确到秒（UTC），排序默认按时间
+// 相同含义的条件规范化后完全相同，用于生成缓存键
+func (q FuzzyQuery) Normalize() FuzzyQuery {
+	q.Keyword = strings.Join(strings.Fields(q.Keyword), " ")
+	sources := make([]string, 0, len(q.Sources))
+	for _, source := range q.Sources {
+		if source = strings.ToLower(strings.TrimSpace(source)); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	slices.Sort(sources)
+	q.Sources = slices.Compact(sources)
+	if len(q.Sources) == 0 {
+		q.Sources = nil
+	}
+	if !q.From.IsZero() {
+		q.From = q.From.UTC().Truncate(time.Second)
+	}
+	if !q.To.IsZero() {
+		q.To = q.To.UTC().Truncate(time.Second)
+	}
+	if q.Sort == "" {
+		q.Sort = FuzzySortTime
+	}
+	return q
+}
+
+// CanonicalKey 规范化条件的唯一表示（调用方先 Normalize）
+func (q FuzzyQuery) CanonicalKey() string {
+	key, _ := json.Marshal(q)
+	return string(key)
+}
+
+// matchesSource 数据源是否在检索范围内
+func (q FuzzyQuery) matchesSource(source string) bool {
+	return len(q.Sources) == 0 || slices.Contains(q.Sources, strings.ToLower(source))
+}
+
+// timeRange 爬取时间范围条件（$match 内容），无范围时返回 nil
+func (q FuzzyQuery) timeRange() bson.D {
+	var cond bson.D
+	if !q.From.IsZero() {
+		cond = append(cond, bson.E{Key: "$gte", Value: primitive.NewDateTimeFromTime(q.From)})
+	}
+	if !q.To.IsZero() {
+		cond = append(cond, bson.E{Key: "$lte", Value: primitive.NewDateTimeFromTime(q.To)})
+	}
+	return cond
+}
+
+// inTimeRange 爬取时间是否在范围内
+func (q FuzzyQuery) inTimeRange(crawledAt time.Time) bool {
+	return (q.From.IsZero() || !crawledAt.Before(q.From)) && (q.To.IsZero() || !crawledAt.After(q.To))
+}
+
+// sortFuzzyResults 按检索条件排序（输入已按时间倒序，稳定排序保证同分时仍按时间倒序）
+func sortFuzzyResults(items []HotItem, query FuzzyQuery) {
+	switch query.Sort {
+	case FuzzySortRelevance:
+		sort.SliceStable(items, func(i, j int) bool {
+			return relevanceScore(items[i], query.Keyword) > relevanceScore(items[j], query.Keyword)
+		})
+	case FuzzySortHot:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].HotValue > items[j].HotValue
+		})
+	}
+}
+
+// fuzzySortKeyField 聚合管道中临时的排序值字段（输出前移除）
+const fuzzySortKeyField = "fuzzy_sort_key"
+
+// fuzzySortStages 单个集合截取结果前的排序条件，与 sortFuzzyResults 的排序方式一致
+// 按热度或估算相关度排序时返回计算排序值的表达式（写入 fuzzySortKeyField），否则 sortKey 为 nil
+func fuzzySortStages(search SearchStrategy, query FuzzyQuery) (sortKey interface{}, sortStage bson.D) {
+	latestFirst := bson.E{Key: crawledAtField, Value: -1}
+	switch {
+	case query.Sort == FuzzySortRelevance && search.Ranked():
+		return nil, bson.D{{Key: searchScoreField, Value: -1}, latestFirst}
+	case query.Sort == FuzzySortRelevance:
+		return relevanceExpr(query.Keyword), bson.D{{Key: fuzzySortKeyField, Value: -1}, latestFirst}
+	case query.Sort == FuzzySortHot:
+		return hotValueExpr(), bson.D{{Key: fuzzySortKeyField, Value: -1}, latestFirst}
+	default:
+		return nil, bson.D{latestFirst}
+	}
+}
+
+// hotValueExpr 热度的聚合表达式，与 decodeHotItem 一致：按 hotItemAliases.hot 的顺序取第一个存在的字段，
+// 数值直接使用，文本按 parseHotValue 的规则解析（去掉逗号，数字后紧跟“亿/万”时换算），无法解析时为 0（需 MongoDB 4.4+）
+func hotValueExpr() bson.D {
+	var raw interface{}
+	for i := len(hotItemAliases.hot) - 1; i >= 0; i-- {
+		raw = bson.D{{Key: "$ifNull", Value: bson.A{"$hotitem." + hotItemAliases.hot[i], raw}}}
+	}
+	captures := "$$hot.captures"
+	text := bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: "hot", Value: bson.D{{Key: "$regexFind", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$replaceAll", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$trim", Value: bson.D{{Key: "input", Value: "$$raw"}}}}},
+				{Key: "find", Value: ","},
+				{Key: "replacement", Value: ""},
+			}}}},
+			{Key: "regex", Value: "^([0-9.]+)(亿|万)?"},
+		}}}}}},
+		{Key: "in", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$$hot", nil}}},
+			0.0,
+			bson.D{{Key: "$multiply", Value: bson.A{
+				bson.D{{Key: "$convert", Value: bson.D{
+					{Key: "input", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{captures, 0}}}},
+					{Key: "to", Value: "double"},
+					{Key: "onError", Value: 0.0},
+					{Key: "onNull", Value: 0.0},
+				}}},
+				bson.D{{Key: "$switch", Value: bson.D{
+					{Key: "branches", Value: bson.A{
+						bson.D{{Key: "case", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$arrayElemAt", Value: bson.A{captures, 1}}}, "亿"}}}}, {Key: "then", Value: 1e8}},
+						bson.D{{Key: "case", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$arrayElemAt", Value: bson.A{captures, 1}}}, "万"}}}}, {Key: "then", Value: 1e4}},
+					}},
+					{Key: "default", Value: 1.0},
+				}}},
+			}}},
+		}}}},
+	}}}
+	return bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: "raw", Value: raw}}},
+		{Key: "in", Value: bson.D{{Key: "$switch", Value: bson.D{
+			{Key: "branches", Value: bson.A{
+				bson.D{{Key: "case", Value: bson.D{{Key: "$isNumber", Value: "$$raw"}}}, {Key: "then", Value: bson.D{{Key: "$toDouble", Value: "$$raw"}}}},
+				bson.D{{Key: "case", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$$raw"}}, "string"}}}}, {Key: "then", Value: text}},
+			}},
+			{Key: "default", Value: 0.0},
+		}}}},
+	}}}
+}
+
+// relevanceExpr 估算相关度的聚合表达式，与 relevanceScore 一致（MongoDB 的 $toLower 只转换 ASCII 字母）
+func relevanceExpr(keyword string) bson.D {
+	keywordLen := utf8.RuneCountInString(keyword)
+	prefix := bson.D{{Key: "$substrCP", Value: bson.A{bson.D{{Key: "$toLower", Value: "$$title"}}, 0, keywordLen}}}
+	return bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: "title", Value: "$" + titleField}}},
+		{Key: "in", Value: bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$divide", Value: bson.A{keywordLen, bson.D{{Key: "$max", Value: bson.A{bson.D{{Key: "$strLenCP", Value: "$$title"}}, 1}}}}}},
+			bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{prefix, strings.ToLower(keyword)}}}, 1, 0}}},
+		}}}},
+	}}}
+}
+
+// relevanceScore 相关度：优先使用 $text 的 textScore，否则按关键词占标题的比例估算（标题以关键词开头时加分）
+func relevanceScore(item HotItem, keyword string) float64 {
+	if item.Score > 0 {
+		return item.Score
+	}
+	if item.Title == "" || keyword == "" {
+		return 0
+	}
+	lowerTitle, lowerKeyword := strings.ToLower(item.Title), strings.ToLower(keyword)
+	score := float64(utf8.RuneCountInString(keyword)) / float64(utf8.RuneCountInString(item.Title))
+	if strings.HasPrefix(lowerTitle, lowerKeyword) {
+		score += 1
+	}
+	return score
+}
+
+// parseHotValue 解析热度字符串（如 "1.2亿"、"345万热度"、"12,345"）
+func parseHotValue(raw string) float64 {
+	raw = strings.ReplaceAll(strings.TrimSpace(raw), ",", "")
+	end := strings.IndexFunc(raw, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	unit := ""
+	if end >= 0 {
+		raw, unit = raw[:end], raw[end:]
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0
+	}
+	switch {
+	case strings.HasPrefix(unit, "亿"):
+		value *= 1e8
+	case strings.HasPrefix(unit, "万"):
+		value *= 1e4
+	}
+	return value
+}
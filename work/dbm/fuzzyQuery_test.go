@@ -0,0 +1,76 @@
+package dbm
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFuzzyQueryCanonicalKey(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	from := time.Date(2026, 1, 2, 8, 0, 0, 0, shanghai)
+	base := FuzzyQuery{Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from.UTC(), Sort: FuzzySortTime}.Normalize()
+
+	// 含义相同的条件规范化后缓存键一致
+	for name, q := range map[string]FuzzyQuery{
+		"whitespace":    {Keyword: "  go \t 发布 ", Sources: []string{"weibo", "zhihu"}, From: from},
+		"source order":  {Keyword: "go 发布", Sources: []string{"zhihu", "weibo"}, From: from},
+		"source case":   {Keyword: "go 发布", Sources: []string{" WEIBO", "Zhihu", "weibo", ""}, From: from},
+		"time zone":     {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from.In(time.UTC)},
+		"sub-second":    {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from.Add(300 * time.Millisecond)},
+		"default sort":  {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from, Sort: ""},
+		"explicit sort": {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from, Sort: FuzzySortTime},
+	} {
+		if got := q.Normalize().CanonicalKey(); got != base.CanonicalKey() {
+			t.Errorf("%s: key %s, want %s", name, got, base.CanonicalKey())
+		}
+	}
+
+	// 条件不同时缓存键不同
+	for name, q := range map[string]FuzzyQuery{
+		"keyword":     {Keyword: "go 发布会", Sources: []string{"weibo", "zhihu"}, From: from},
+		"sources":     {Keyword: "go 发布", Sources: []string{"weibo"}, From: from},
+		"all sources": {Keyword: "go 发布", From: from},
+		"from":        {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from.Add(time.Second)},
+		"to":          {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from, To: from.Add(time.Hour)},
+		"sort":        {Keyword: "go 发布", Sources: []string{"weibo", "zhihu"}, From: from, Sort: FuzzySortHot},
+	} {
+		if got := q.Normalize().CanonicalKey(); got == base.CanonicalKey() {
+			t.Errorf("%s: key should differ from base", name)
+		}
+	}
+
+	if (FuzzyQuery{Keyword: "x", Sources: []string{" ", ""}}).Normalize().Sources != nil {
+		t.Error("blank sources should normalize to nil (all sources)")
+	}
+}
+
+func TestFuzzySortStages(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		search   SearchStrategy
+		sort     string
+		wantKey  bool
+		wantHead string
+	}{
+		{"time", regexStrategy{}, FuzzySortTime, false, crawledAtField},
+		{"hot", regexStrategy{}, FuzzySortHot, true, fuzzySortKeyField},
+		{"relevance estimated", ngramStrategy{}, FuzzySortRelevance, true, fuzzySortKeyField},
+		{"relevance ranked", textStrategy{}, FuzzySortRelevance, false, searchScoreField},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, stage := fuzzySortStages(tc.search, FuzzyQuery{Keyword: "go", Sort: tc.sort}.Normalize())
+			if (key != nil) != tc.wantKey {
+				t.Fatalf("sort key = %v, want present=%v", key, tc.wantKey)
+			}
+			if len(stage) == 0 || stage[0].Key != tc.wantHead {
+				t.Fatalf("sort stage = %v, want leading %s", stage, tc.wantHead)
+			}
+			// 同分时按爬取时间倒序，保证分页稳定
+			if last := stage[len(stage)-1]; last != (bson.E{Key: crawledAtField, Value: -1}) {
+				t.Fatalf("sort stage = %v, want crawled_at tiebreak", stage)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package dbm
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BatchDiff 同一数据源两个批次之间的榜单变化（Base 为较早批次，Target 为较新批次）
+type BatchDiff struct {
+	Source  string       `json:"source"`
+	Base    time.Time    `json:"base"`    // 较早批次的爬取时间
+	Target  time.Time    `json:"target"`  // 较新批次的爬取时间
+	New     []HotItem    `json:"new"`     // 新上榜（按当前名次）
+	Dropped []HotItem    `json:"dropped"` // 已下榜（按原名次）
+	Risers  []ItemChange `json:"risers"`  // 名次上升（按上升幅度倒序）
+	Fallers []ItemChange `json:"fallers"` // 名次下降（按下降幅度倒序）
+	Steady  []ItemChange `json:"steady"`  // 名次不变（按名次）
+}
+
+// ItemChange 两个批次中都在榜的条目
+type ItemChange struct {
+	Title        string  `json:"title"`
+	URL          string  `json:"url"`
+	Rank         int     `json:"rank"`
+	PrevRank     int     `json:"prev_rank"`
+	RankDelta    int     `json:"rank_delta"` // 名次变化，正数表示上升
+	HotValue     float64 `json:"hot_value"`
+	PrevHotValue float64 `json:"prev_hot_value"`
+	HotDelta     float64 `json:"hot_delta"`
+}
+
+// DiffBatches 对比两个批次，条目按规范化后的标题（去首尾空白、合并连续空白、忽略大小写）对应
+func DiffBatches(base, target *HotBatch) BatchDiff {
+	diff := BatchDiff{
+		Source:  target.Source,
+		Base:    base.CrawledAt,
+		Target:  target.CrawledAt,
+		New:     []HotItem{},
+		Dropped: []HotItem{},
+		Risers:  []ItemChange{},
+		Fallers: []ItemChange{},
+		Steady:  []ItemChange{},
+	}
+	before := make(map[string]HotItem, len(base.Items))
+	for _, item := range base.Items {
+		key := diffKey(item.Title)
+		if _, exists := before[key]; !exists {
+			before[key] = item
+		}
+	}
+	seen := make(map[string]bool, len(target.Items))
+	for _, item := range target.Items {
+		key := diffKey(item.Title)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		prev, ok := before[key]
+		if !ok {
+			diff.New = append(diff.New, item)
+			continue
+		}
+		change := ItemChange{
+			Title:        item.Title,
+			URL:          item.URL,
+			Rank:         item.Rank,
+			PrevRank:     prev.Rank,
+			RankDelta:    prev.Rank - item.Rank,
+			HotValue:     item.HotValue,
+			PrevHotValue: prev.HotValue,
+			HotDelta:     item.HotValue - prev.HotValue,
+		}
+		switch {
+		case change.RankDelta > 0:
+			diff.Risers = append(diff.Risers, change)
+		case change.RankDelta < 0:
+			diff.Fallers = append(diff.Fallers, change)
+		default:
+			diff.Steady = append(diff.Steady, change)
+		}
+	}
+	for _, item := range base.Items {
+		key := diffKey(item.Title)
+		if !seen[key] {
+			seen[key] = true
+			diff.Dropped = append(diff.Dropped, item)
+		}
+	}
+
+	byRank := func(a, b HotItem) int { return cmp.Compare(a.Rank, b.Rank) }
+	slices.SortStableFunc(diff.New, byRank)
+	slices.SortStableFunc(diff.Dropped, byRank)
+	slices.SortStableFunc(diff.Risers, func(a, b ItemChange) int {
+		return cmp.Or(cmp.Compare(b.RankDelta, a.RankDelta), cmp.Compare(a.Rank, b.Rank))
+	})
+	slices.SortStableFunc(diff.Fallers, func(a, b ItemChange) int {
+		return cmp.Or(cmp.Compare(a.RankDelta, b.RankDelta), cmp.Compare(a.Rank, b.Rank))
+	})
+	slices.SortStableFunc(diff.Steady, func(a, b ItemChange) int { return cmp.Compare(a.Rank, b.Rank) })
+	return diff
+}
+
+func diffKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
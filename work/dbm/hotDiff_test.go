@@ -0,0 +1,128 @@
+package dbm
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// diffBatch 按标题顺序生成批次，名次为位置 + 1
+func diffBatch(crawledAt time.Time, titles ...string) *HotBatch {
+	batch := &HotBatch{Source: "weibo", CrawledAt: crawledAt}
+	for i, title := range titles {
+		batch.Items = append(batch.Items, HotItem{Title: title, Rank: i + 1, HotValue: float64(100 - i)})
+	}
+	return batch
+}
+
+func itemTitles(items []HotItem) []string {
+	titles := []string{}
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func changeSummary(changes []ItemChange) []string {
+	out := []string{}
+	for _, c := range changes {
+		out = append(out, c.Title)
+	}
+	return out
+}
+
+func TestDiffBatches(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	for _, tc := range []struct {
+		name                  string
+		base, target          *HotBatch
+		newT, dropped, steady []string
+		risers, fallers       []string
+	}{
+		{
+			name:    "identical",
+			base:    diffBatch(t0, "a", "b"),
+			target:  diffBatch(t1, "a", "b"),
+			newT:    []string{},
+			dropped: []string{},
+			steady:  []string{"a", "b"},
+			risers:  []string{},
+			fallers: []string{},
+		},
+		{
+			name:    "new and dropped by rank",
+			base:    diffBatch(t0, "a", "x", "b", "y"),
+			target:  diffBatch(t1, "a", "n1", "b", "n2"),
+			newT:    []string{"n1", "n2"},
+			dropped: []string{"x", "y"},
+			steady:  []string{"a", "b"},
+			risers:  []string{},
+			fallers: []string{},
+		},
+		{
+			// 标题按去首尾空白、合并连续空白、忽略大小写对应
+			name:    "normalized titles",
+			base:    diffBatch(t0, "Go  Release", " rust "),
+			target:  diffBatch(t1, "go release", "RUST"),
+			newT:    []string{},
+			dropped: []string{},
+			steady:  []string{"go release", "RUST"},
+			risers:  []string{},
+			fallers: []string{},
+		},
+		{
+			// 重复标题只取第一次出现（名次最靠前）
+			name:    "duplicate titles",
+			base:    diffBatch(t0, "a", "b", "A"),
+			target:  diffBatch(t1, "b", "a", "a"),
+			newT:    []string{},
+			dropped: []string{},
+			steady:  []string{},
+			risers:  []string{"b"},
+			fallers: []string{"a"},
+		},
+		{
+			// 上升按幅度倒序、同幅度按当前名次；下降同理
+			name:    "risers and fallers order",
+			base:    diffBatch(t0, "a", "b", "c", "d", "e", "f"),
+			target:  diffBatch(t1, "f", "e", "a", "b", "c", "d"),
+			newT:    []string{},
+			dropped: []string{},
+			steady:  []string{},
+			risers:  []string{"f", "e"},
+			fallers: []string{"a", "b", "c", "d"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := DiffBatches(tc.base, tc.target)
+			if !diff.Base.Equal(t0) || !diff.Target.Equal(t1) || diff.Source != "weibo" {
+				t.Fatalf("header = %s %v %v", diff.Source, diff.Base, diff.Target)
+			}
+			check := func(kind string, got, want []string) {
+				if !slices.Equal(got, want) {
+					t.Errorf("%s = %v, want %v", kind, got, want)
+				}
+			}
+			check("new", itemTitles(diff.New), tc.newT)
+			check("dropped", itemTitles(diff.Dropped), tc.dropped)
+			check("steady", changeSummary(diff.Steady), tc.steady)
+			check("risers", changeSummary(diff.Risers), tc.risers)
+			check("fallers", changeSummary(diff.Fallers), tc.fallers)
+		})
+	}
+}
+
+func TestDiffBatchesDeltas(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	base := &HotBatch{Items: []HotItem{{Title: "a", Rank: 5, HotValue: 100}}, CrawledAt: t0}
+	target := &HotBatch{Items: []HotItem{{Title: "a", Rank: 2, HotValue: 250, URL: "u"}}, CrawledAt: t0.Add(time.Minute)}
+	diff := DiffBatches(base, target)
+	if len(diff.Risers) != 1 {
+		t.Fatalf("risers = %+v", diff.Risers)
+	}
+	want := ItemChange{Title: "a", URL: "u", Rank: 2, PrevRank: 5, RankDelta: 3, HotValue: 250, PrevHotValue: 100, HotDelta: 150}
+	if diff.Risers[0] != want {
+		t.Fatalf("change = %+v, want %+v", diff.Risers[0], want)
+	}
+}
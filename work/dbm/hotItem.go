@@ -0,0 +1,297 @@
+package dbm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HotSchemaVersion 对外 JSON 契约版本（HotItem/HotBatch 字段有不兼容变更时递增）
+const HotSchemaVersion = 1
+
+// HotItem 热榜条目（对外 JSON 契约，字段只增不改）
+// 解码时兼容爬虫不同版本的字段名（见 hotItemAliases），热度同时支持数值和“123万”形式的文本
+type HotItem struct {
+	Title     string    `bson:"title" json:"title"`
+	URL       string    `bson:"url" json:"url"`
+	Rank      int       `bson:"rank" json:"rank"`                            // 榜单名次（从 1 开始，0 表示未知）
+	HotValue  float64   `bson:"hotvalue" json:"hot_value"`                   // 热度数值
+	HotText   string    `bson:"hottext,omitempty" json:"hot_text,omitempty"` // 热度原文（如“123.4万”）
+	Source    string    `bson:"source" json:"source"`                        // 数据源（bilibili/weibo/zhihu…）
+	CrawledAt time.Time `bson:"crawledat" json:"crawled_at"`                 // 爬取时间
+	Score     float64   `bson:"score,omitempty" json:"score,omitempty"`      // 检索相关度（仅模糊检索结果）
+}
+
+// HotBatch 某数据源一次爬取得到的完整热榜
+type HotBatch struct {
+	SchemaVersion int       `json:"schema_version"`
+	Source        string    `json:"source"`
+	CrawledAt     time.Time `json:"crawled_at"`
+	Items         []HotItem `json:"items"`
+}
+
+// hotItemAliases 各字段在历史数据中出现过的名称（按优先级）
+var hotItemAliases = struct {
+	title, url, rank, hot, hotText, source, crawledAt []string
+}{
+	title:     []string{"title", "name", "word"},
+	url:       []string{"url", "link", "href"},
+	rank:      []string{"rank", "index", "position"},
+	hot:       []string{"hot_value", "hotvalue", "hotValue", "hot", "heat"},
+	hotText:   []string{"hot_text", "hottext"},
+	source:    []string{"source", "platform"},
+	crawledAt: []string{"crawled_at", "crawledat", "crawledAt", "time"},
+}
+
+var errMissingTitle = errors.New("hot item missing title")
+
+// decodeHotItem 从通用文档（bson.M 或 JSON 解码的 map）解析条目，兼容 {hotitem: {...}} 包装
+// source 为文档中无数据源字段时使用的默认值
+func decodeHotItem(doc map[string]interface{}, source string) (HotItem, error) {
+	fields := doc
+	if nested := asMap(doc["hotitem"]); nested != nil {
+		fields = nested
+	}
+	item := HotItem{
+		Title:     firstString(fields, hotItemAliases.title),
+		URL:       firstString(fields, hotItemAliases.url),
+		Rank:      int(firstNumber(fields, hotItemAliases.rank)),
+		HotText:   firstString(fields, hotItemAliases.hotText),
+		Source:    firstString(fields, hotItemAliases.source),
+		CrawledAt: firstTime(fields, hotItemAliases.crawledAt),
+	}
+	if item.Title == "" {
+		return item, errMissingTitle
+	}
+	if item.Source == "" {
+		item.Source = firstString(doc, hotItemAliases.source)
+	}
+	if item.Source == "" {
+		item.Source = source
+	}
+	for _, key := range hotItemAliases.hot {
+		switch v := fields[key].(type) {
+		case nil:
+			continue
+		case string:
+			item.HotValue = parseHotValue(v)
+			if item.HotText == "" {
+				item.HotText = v
+			}
+		default:
+			item.HotValue = toFloat(v)
+		}
+		break
+	}
+	if score, ok := doc[searchScoreField].(float64); ok {
+		item.Score = score
+	}
+	return item, nil
+}
+
+// UnmarshalJSON 兼容历史字段名的解码
+func (h *HotItem) UnmarshalJSON(data []byte) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	item, err := decodeHotItem(doc, "")
+	if err != nil {
+		return err
+	}
+	*h = item
+	return nil
+}
+
+// UnmarshalJSON 兼容三种历史格式：条目数组、{items|data|list: [...]} 对象，以及 schema_version 不同的对象
+// 解析失败的单个条目被跳过，不影响整批数据
+func (b *HotBatch) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	batch := HotBatch{SchemaVersion: HotSchemaVersion}
+	var rawItems []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		rawItems = v
+	case map[string]interface{}:
+		batch.Source = firstString(v, hotItemAliases.source)
+		batch.CrawledAt = firstTime(v, hotItemAliases.crawledAt)
+		for _, key := range []string{"items", "data", "list", "hotitems"} {
+			if items, ok := v[key].([]interface{}); ok {
+				rawItems = items
+				break
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected hot batch json type %T", raw)
+	}
+	for _, rawItem := range rawItems {
+		doc := asMap(rawItem)
+		if doc == nil {
+			continue
+		}
+		item, err := decodeHotItem(doc, batch.Source)
+		if err != nil {
+			continue
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	batch.fillDefaults()
+	*b = batch
+	return nil
+}
+
+// fillDefaults 补全批次缺省字段：条目无名次时按顺序编号，批次无时间时取条目中最新的爬取时间
+func (b *HotBatch) fillDefaults() {
+	if b.Items == nil {
+		b.Items = []HotItem{}
+	}
+	for i := range b.Items {
+		if b.Items[i].Rank == 0 {
+			b.Items[i].Rank = i + 1
+		}
+		if b.Items[i].Source == "" {
+			b.Items[i].Source = b.Source
+		}
+		if b.Items[i].CrawledAt.IsZero() {
+			b.Items[i].CrawledAt = b.CrawledAt
+		}
+		if b.CrawledAt.IsZero() || b.Items[i].CrawledAt.After(b.CrawledAt) {
+			b.CrawledAt = b.Items[i].CrawledAt
+		}
+	}
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case bson.M:
+		return m
+	case bson.D:
+		fields := make(map[string]interface{}, len(m))
+		for _, e := range m {
+			fields[e.Key] = e.Value
+		}
+		return fields
+	default:
+		return nil
+	}
+}
+
+func firstString(fields map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case nil:
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func firstNumber(fields map[string]interface{}, keys []string) float64 {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok && v != nil {
+			return toFloat(v)
+		}
+	}
+	return 0
+}
+
+// toFloat 数值类型（含 BSON/JSON 解码得到的各类整数、浮点和数字字符串）转 float64
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0
+		}
+		return n
+	case primitive.Decimal128:
+		f, _ := strconv.ParseFloat(n.String(), 64)
+		return f
+	case string:
+		return parseHotValue(n)
+	default:
+		return 0
+	}
+}
+
+// firstTime 解析时间：BSON 日期、RFC3339 字符串、Unix 秒或毫秒
+func firstTime(fields map[string]interface{}, keys []string) time.Time {
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case primitive.DateTime:
+			return v.Time()
+		case time.Time:
+			return v
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(v)); err == nil {
+				return t
+			}
+		case nil:
+		default:
+			if n := toFloat(v); n > 0 {
+				if n > 1e12 { // 毫秒
+					return time.UnixMilli(int64(n))
+				}
+				return time.Unix(int64(n), 0)
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// HotSchema 对外数据结构的 JSON Schema（供客户端生成代码）
+func HotSchema() map[string]interface{} {
+	item := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"title", "url", "rank", "hot_value", "source", "crawled_at"},
+		"properties": map[string]interface{}{
+			"title":      map[string]string{"type": "string"},
+			"url":        map[string]string{"type": "string"},
+			"rank":       map[string]string{"type": "integer", "description": "榜单名次，从 1 开始，0 表示未知"},
+			"hot_value":  map[string]string{"type": "number", "description": "热度数值"},
+			"hot_text":   map[string]string{"type": "string", "description": "热度原文"},
+			"source":     map[string]string{"type": "string"},
+			"crawled_at": map[string]string{"type": "string", "format": "date-time"},
+			"score":      map[string]string{"type": "number", "description": "检索相关度，仅模糊检索结果"},
+		},
+	}
+	return map[string]interface{}{
+		"$schema":        "https://json-schema.org/draft/2020-12/schema",
+		"schema_version": HotSchemaVersion,
+		"$defs": map[string]interface{}{
+			"HotItem": item,
+			"HotBatch": map[string]interface{}{
+				"type":     "object",
+				"required": []string{"schema_version", "source", "crawled_at", "items"},
+				"properties": map[string]interface{}{
+					"schema_version": map[string]string{"type": "integer"},
+					"source":         map[string]string{"type": "string"},
+					"crawled_at":     map[string]string{"type": "string", "format": "date-time"},
+					"items":          map[string]interface{}{"type": "array", "items": map[string]string{"$ref": "#/$defs/HotItem"}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package dbm
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseHotValue(t *testing.T) {
+	for raw, want := range map[string]float64{
+		"12345":     12345,
+		"12,345":    12345,
+		" 1,234.5 ": 1234.5,
+		"1.2亿":      1.2e8,
+		"123.4万":    1.234e6,
+		"56万热度":     5.6e5,
+		"987 热度":    987,
+		"":          0,
+		"热":         0,
+		"hot 123":   0,
+		"1.2.3万":    0,
+	} {
+		if got := parseHotValue(raw); math.Abs(got-want) > 1e-6 {
+			t.Errorf("parseHotValue(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestDecodeHotItemAliases(t *testing.T) {
+	crawled := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		doc  map[string]interface{}
+		want HotItem
+	}{
+		{
+			name: "current fields",
+			doc:  map[string]interface{}{"title": "A", "url": "u", "rank": 3, "hot_value": 1.5, "source": "weibo", "crawled_at": crawled.Format(time.RFC3339)},
+			want: HotItem{Title: "A", URL: "u", Rank: 3, HotValue: 1.5, Source: "weibo", CrawledAt: crawled},
+		},
+		{
+			name: "legacy aliases",
+			doc:  map[string]interface{}{"word": "B", "link": "l", "index": int32(2), "heat": int64(7), "platform": "zhihu", "time": primitive.NewDateTimeFromTime(crawled)},
+			want: HotItem{Title: "B", URL: "l", Rank: 2, HotValue: 7, Source: "zhihu", CrawledAt: crawled},
+		},
+		{
+			// 多个别名同时存在时按 hotItemAliases 中的顺序取第一个非空值
+			name: "alias precedence",
+			doc:  map[string]interface{}{"name": "second", "title": "first", "hot": 1.0, "hotvalue": 2.0, "href": "h", "url": ""},
+			want: HotItem{Title: "first", URL: "h", HotValue: 2, Source: "default"},
+		},
+		{
+			name: "hot text",
+			doc:  map[string]interface{}{"title": "C", "hot": "1.2亿"},
+			want: HotItem{Title: "C", HotValue: 1.2e8, HotText: "1.2亿", Source: "default"},
+		},
+		{
+			name: "explicit hot text kept",
+			doc:  map[string]interface{}{"title": "D", "hotValue": "12,345", "hot_text": "1.2万"},
+			want: HotItem{Title: "D", HotValue: 12345, HotText: "1.2万", Source: "default"},
+		},
+		{
+			name: "nested hotitem with outer source",
+			doc:  map[string]interface{}{"source": "bilibili", "hotitem": bson.D{{Key: "title", Value: "E"}, {Key: "crawledAt", Value: crawled}}},
+			want: HotItem{Title: "E", Source: "bilibili", CrawledAt: crawled},
+		},
+		{
+			name: "unix seconds",
+			doc:  map[string]interface{}{"title": "F", "crawledat": float64(crawled.Unix())},
+			want: HotItem{Title: "F", Source: "default", CrawledAt: crawled},
+		},
+		{
+			name: "unix milliseconds",
+			doc:  map[string]interface{}{"title": "G", "crawledat": float64(crawled.UnixMilli())},
+			want: HotItem{Title: "G", Source: "default", CrawledAt: crawled},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeHotItem(tc.doc, "default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.CrawledAt.Equal(tc.want.CrawledAt) {
+				t.Fatalf("crawled_at = %v, want %v", got.CrawledAt, tc.want.CrawledAt)
+			}
+			got.CrawledAt, tc.want.CrawledAt = time.Time{}, time.Time{}
+			if math.Abs(got.HotValue-tc.want.HotValue) > 1e-6 {
+				t.Fatalf("hot_value = %v, want %v", got.HotValue, tc.want.HotValue)
+			}
+			got.HotValue, tc.want.HotValue = 0, 0
+			if got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := decodeHotItem(map[string]interface{}{"url": "u"}, "weibo"); err != errMissingTitle {
+		t.Fatalf("missing title err = %v", err)
+	}
+}
+
+func TestHotBatchUnmarshalShapes(t *testing.T) {
+	crawled := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ms := crawled.UnixMilli()
+	for _, tc := range []struct {
+		name       string
+		raw        string
+		wantSource string
+		wantTitles []string
+	}{
+		{
+			name:       "bare array",
+			raw:        `[{"title":"a","crawled_at":` + jsonInt(ms) + `},{"name":"b"},{"url":"no title"},"junk"]`,
+			wantTitles: []string{"a", "b"},
+		},
+		{
+			name:       "wrapped list",
+			raw:        `{"platform":"weibo","time":` + jsonInt(ms) + `,"list":[{"word":"a"},{"title":"b","rank":5}]}`,
+			wantSource: "weibo",
+			wantTitles: []string{"a", "b"},
+		},
+		{
+			name:       "versioned object",
+			raw:        `{"schema_version":1,"source":"zhihu","crawled_at":"` + crawled.Format(time.RFC3339) + `","items":[{"title":"a","source":"zhihu"}]}`,
+			wantSource: "zhihu",
+			wantTitles: []string{"a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var batch HotBatch
+			if err := json.Unmarshal([]byte(tc.raw), &batch); err != nil {
+				t.Fatal(err)
+			}
+			if batch.SchemaVersion != HotSchemaVersion || batch.Source != tc.wantSource || !batch.CrawledAt.Equal(crawled) {
+				t.Fatalf("batch header = %d %q %v", batch.SchemaVersion, batch.Source, batch.CrawledAt)
+			}
+			if len(batch.Items) != len(tc.wantTitles) {
+				t.Fatalf("items = %+v, want titles %v", batch.Items, tc.wantTitles)
+			}
+			for i, item := range batch.Items {
+				// 缺省名次按顺序编号（显式名次保留），缺省数据源和时间取批次的值
+				wantRank := i + 1
+				if item.Title == "b" && tc.name == "wrapped list" {
+					wantRank = 5
+				}
+				if item.Title != tc.wantTitles[i] || item.Rank != wantRank || item.Source != tc.wantSource || !item.CrawledAt.Equal(crawled) {
+					t.Fatalf("item %d = %+v", i, item)
+				}
+			}
+		})
+	}
+
+	var batch HotBatch
+	if err := json.Unmarshal([]byte(`"text"`), &batch); err == nil {
+		t.Fatal("non array/object batch should fail")
+	}
+}
+
+func jsonInt(n int64) string {
+	raw, _ := json.Marshal(n)
+	return string(raw)
+}
@@ -0,0 +1,467 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore 进程内存储，实现全部存储接口（用于测试和无外部依赖的单机运行）
+// 过期时间在读取时惰性判断
+type MemoryStore struct {
+	mu sync.Mutex
+
+	batches  map[string][]*HotBatch // source → 批次数据（按写入顺序，最后一个最新）
+	hotItems map[string][]HotItem   // source → 可被模糊检索的历史条目
+
+	hashes map[string]memoryEntry[map[string]string]
+	locks  map[string]memoryEntry[string]
+
+	users     map[uint64]*User
+	subs      []Subscription // 按创建顺序
+	nextUser  uint64
+	refresh   map[string]memoryEntry[RefreshTokenOwner]
+	revoked   map[string]time.Time // jti → 过期时间
+	maxBatchs int
+
+	subscribers map[string]map[chan []byte]struct{} // 频道 → 订阅者
+}
+
+type memoryEntry[T any] struct {
+	value    T
+	expireAt time.Time // 零值表示不过期
+}
+
+func (e memoryEntry[T]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// NewMemoryStore 创建进程内存储，maxBatches 为每个数据源保留的批次数
+func NewMemoryStore(maxBatches int) *MemoryStore {
+	return &MemoryStore{
+		batches:   make(map[string][]*HotBatch),
+		hotItems:  make(map[string][]HotItem),
+		hashes:    make(map[string]memoryEntry[map[string]string]),
+		locks:     make(map[string]memoryEntry[string]),
+		users:     make(map[uint64]*User),
+		nextUser:  userIDStart,
+		refresh:   make(map[string]memoryEntry[RefreshTokenOwner]),
+		revoked:   make(map[string]time.Time),
+		maxBatchs: maxBatches,
+
+		subscribers: make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+var (
+	_ HotDataStore      = (*MemoryStore)(nil)
+	_ FuzzySearchStore  = (*MemoryStore)(nil)
+	_ TrendStore        = (*MemoryStore)(nil)
+	_ CacheStore        = (*MemoryStore)(nil)
+	_ Locker            = (*MemoryStore)(nil)
+	_ UserStore         = (*MemoryStore)(nil)
+	_ SubscriptionStore = (*MemoryStore)(nil)
+	_ TokenStore        = (*MemoryStore)(nil)
+	_ EventBus          = (*MemoryStore)(nil)
+)
+
+// PutBatch 写入某数据源的一个新批次（超出 maxBatches 时淘汰最旧批次）
+func (m *MemoryStore) PutBatch(batch HotBatch) {
+	if batch.SchemaVersion == 0 {
+		batch.SchemaVersion = HotSchemaVersion
+	}
+	batch.Items = slices.Clone(batch.Items)
+	batch.fillDefaults()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batches := append(m.batches[batch.Source], &batch)
+	if m.maxBatchs > 0 && len(batches) > m.maxBatchs {
+		batches = batches[len(batches)-m.maxBatchs:]
+	}
+	m.batches[batch.Source] = batches
+}
+
+// PutHotItems 写入某数据源可被模糊检索的历史条目
+func (m *MemoryStore) PutHotItems(source string, items ...HotItem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range items {
+		if item.Source == "" {
+			item.Source = source
+		}
+		m.hotItems[source] = append(m.hotItems[source], item)
+	}
+}
+
+func (m *MemoryStore) GetLatestDataBySource(ctx context.Context, source string) (*HotBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batches := m.batches[source]
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("no data found for source: %s", source)
+	}
+	return cloneBatch(batches[len(batches)-1]), nil
+}
+
+func (m *MemoryStore) GetLatestDataBySources(ctx context.Context, sources []string) (map[string]*HotBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[string]*HotBatch, len(sources))
+	for _, source := range sources {
+		if batches := m.batches[source]; len(batches) > 0 {
+			result[source] = cloneBatch(batches[len(batches)-1])
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) GetBatchHistory(ctx context.Context, source string, from, to time.Time) ([]*HotBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	batches := m.batches[source]
+	result := make([]*HotBatch, 0, len(batches))
+	for i := len(batches) - 1; i >= 0; i-- {
+		crawledAt := batches[i].CrawledAt
+		if (from.IsZero() || !crawledAt.Before(from)) && (to.IsZero() || !crawledAt.After(to)) {
+			result = append(result, cloneBatch(batches[i]))
+		}
+	}
+	return result, nil
+}
+
+// cloneBatch 复制批次，避免调用方修改存储中的数据
+func cloneBatch(batch *HotBatch) *HotBatch {
+	clone := *batch
+	clone.Items = slices.Clone(batch.Items)
+	return &clone
+}
+
+func (m *MemoryStore) GetMongoDataFuzzyByKeyword(ctx context.Context, query FuzzyQuery, onProgress FuzzyProgress) ([]HotItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if onProgress != nil {
+		defer onProgress(1, 1, "memory") // 内存数据视为单个集合（在释放锁后回调）
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keyword := strings.ToLower(query.Keyword)
+	var matched []HotItem
+	for source, items := range m.hotItems {
+		if !query.matchesSource(source) {
+			continue
+		}
+		for _, item := range items {
+			if strings.Contains(strings.ToLower(item.Title), keyword) && query.inTimeRange(item.CrawledAt) {
+				matched = append(matched, item)
+			}
+		}
+	}
+	results := deduplicateAndSort(matched, len(matched))
+	sortFuzzyResults(results, query)
+	if len(results) > MaxFuzzyResults {
+		results = results[:MaxFuzzyResults]
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) GetTitleTrend(ctx context.Context, query TrendQuery) ([]TrendSeries, error) {
+	if query.Interval < time.Millisecond {
+		return nil, errors.New("trend interval too small")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inRange := FuzzyQuery{From: query.From, To: query.To}
+	offset := query.bucketOffset()
+	sources := slices.Sorted(maps.Keys(m.hotItems))
+	series := []TrendSeries{}
+	for _, source := range sources {
+		if len(query.Sources) > 0 && !slices.Contains(query.Sources, source) {
+			continue
+		}
+		var matched []HotItem
+		for _, item := range m.hotItems[source] {
+			if item.Title == query.Title && inRange.inTimeRange(item.CrawledAt) {
+				matched = append(matched, item)
+			}
+		}
+		slices.SortStableFunc(matched, func(a, b HotItem) int { return a.CrawledAt.Compare(b.CrawledAt) })
+		var points []TrendPoint
+		for start := 0; start < len(matched); {
+			bucket := trendBucket(matched[start].CrawledAt, query.Interval, offset)
+			end := start + 1
+			for end < len(matched) && trendBucket(matched[end].CrawledAt, query.Interval, offset).Equal(bucket) {
+				end++
+			}
+			points = append(points, newTrendPoint(bucket, matched[start:end]))
+			start = end
+		}
+		if len(points) > 0 {
+			series = append(series, TrendSeries{Source: source, Points: points})
+		}
+	}
+	return series, nil
+}
+
+func (m *MemoryStore) GetHash(ctx context.Context, key string) (map[string]string, error) {
+	return m.GetHashConsistent(ctx, key)
+}
+
+func (m *MemoryStore) GetHashConsistent(ctx context.Context, key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.hashes[key]
+	if !ok || entry.expired(time.Now()) {
+		delete(m.hashes, key)
+		return map[string]string{}, nil
+	}
+	return maps.Clone(entry.value), nil
+}
+
+func (m *MemoryStore) SetHash(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.hashes[key]
+	if !ok || entry.expired(time.Now()) {
+		entry = memoryEntry[map[string]string]{value: map[string]string{}}
+	}
+	maps.Copy(entry.value, fields)
+	entry.expireAt = expireAt(ttl)
+	m.hashes[key] = entry
+	return nil
+}
+
+func (m *MemoryStore) TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.locks[key]; ok && !entry.expired(time.Now()) {
+		return false, nil
+	}
+	m.locks[key] = memoryEntry[string]{value: token, expireAt: expireAt(ttl)}
+	return true, nil
+}
+
+func (m *MemoryStore) Unlock(ctx context.Context, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.locks[key]; ok && entry.value == token {
+		delete(m.locks, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, username, passwordHash, nickname string, roles []string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return nil, ErrUserExists
+		}
+	}
+	if nickname == "" {
+		nickname = username
+	}
+	m.nextUser++
+	now := time.Now()
+	user := &User{
+		UserID:       m.nextUser,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Nickname:     nickname,
+		Roles:        slices.Clone(roles),
+		Permissions:  []string{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	m.users[user.UserID] = user
+	copied := *user
+	return &copied, nil
+}
+
+func (m *MemoryStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, userID uint64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (m *MemoryStore) UpdateUserRoles(ctx context.Context, userID uint64, roles, permissions []string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	u.Roles = slices.Clone(roles)
+	u.Permissions = slices.Clone(permissions)
+	u.UpdatedAt = time.Now()
+	copied := *u
+	return &copied, nil
+}
+
+func (m *MemoryStore) SaveRefreshToken(ctx context.Context, tokenHash string, owner RefreshTokenOwner, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refresh[tokenHash] = memoryEntry[RefreshTokenOwner]{value: owner, expireAt: expireAt(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenOwner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.refresh[tokenHash]
+	delete(m.refresh, tokenHash)
+	if !ok || entry.expired(time.Now()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	owner := entry.value
+	return &owner, nil
+}
+
+func (m *MemoryStore) DeleteRefreshToken(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.refresh, tokenHash)
+	return nil
+}
+
+func (m *MemoryStore) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[jti] = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *MemoryStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.revoked[jti]
+	if ok && time.Now().After(until) {
+		delete(m.revoked, jti)
+		return false, nil
+	}
+	return ok, nil
+}
+
+// PublishEvent 投递给当前订阅者；订阅者缓冲已满时丢弃（与 Redis 慢订阅者行为一致）
+func (m *MemoryStore) PublishEvent(ctx context.Context, channel string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subscribers[channel] {
+		select {
+		case ch <- slices.Clone(payload):
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) SubscribeEvents(ctx context.Context, channel string) (<-chan []byte, error) {
+	ch := make(chan []byte, 16)
+	m.mu.Lock()
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[chan []byte]struct{})
+	}
+	m.subscribers[channel][ch] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers[channel], ch)
+		if len(m.subscribers[channel]) == 0 {
+			delete(m.subscribers, channel)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func (m *MemoryStore) CreateSubscription(ctx context.Context, sub Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, sub)
+	return nil
+}
+
+func (m *MemoryStore) CountSubscriptions(ctx context.Context, userID uint64) (int, error) {
+	subs, err := m.ListSubscriptions(ctx, userID)
+	return len(subs), err
+}
+
+func (m *MemoryStore) ListSubscriptions(ctx context.Context, userID uint64) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := []Subscription{}
+	for _, sub := range m.subs {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (m *MemoryStore) ListAllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.subs), nil
+}
+
+func (m *MemoryStore) DeleteSubscription(ctx context.Context, userID uint64, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.subs, func(sub Subscription) bool { return sub.ID == id && sub.UserID == userID })
+	if idx < 0 {
+		return ErrSubscriptionNotFound
+	}
+	m.subs = slices.Delete(m.subs, idx, idx+1)
+	return nil
+}
+
+func (m *MemoryStore) VerifySubscriptionEmail(ctx context.Context, userID uint64, id, codeHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idx := slices.IndexFunc(m.subs, func(sub Subscription) bool { return sub.ID == id && sub.UserID == userID })
+	if idx < 0 {
+		return ErrSubscriptionNotFound
+	}
+	sub := &m.subs[idx]
+	if sub.EmailCodeHash == "" || sub.EmailCodeHash != codeHash || !time.Now().Before(sub.EmailCodeExp) {
+		return ErrEmailCodeInvalid
+	}
+	sub.EmailVerified = true
+	sub.EmailCodeHash, sub.EmailCodeExp = "", time.Time{}
+	return nil
+}
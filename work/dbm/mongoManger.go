@@ -9,44 +9,70 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type MongoManger struct {
-	mongoClient      *mongo.Client // MongoDB 客户端
-	mongodbDatasName string        //数据库爬取数据库
-	mongodbUsersName string        //数据库用户库
+	mongoClient      *mongo.Client  // MongoDB 客户端
+	mongodbDatasName string         //数据库爬取数据库
+	mongodbUsersName string         //数据库用户库
+	search           SearchStrategy // 模糊检索策略（fuzzy_search.strategy）
 }
 
 func (m *MongoManger) GetMongoClient() *mongo.Client {
 	return m.mongoClient
 }
 
-// mongo数据库查询返回模糊查询文档
-func (m *MongoManger) GetMongoDataFuzzyByKeyword(keyword string) (interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// mongo数据库查询返回模糊查询文档（按 query 过滤数据源和时间范围、排序，最多 MaxFuzzyResults 条）
+// 每个数据源对应一个同名集合，文档未记录数据源时以集合名作为 source
+func (m *MongoManger) GetMongoDataFuzzyByKeyword(ctx context.Context, query FuzzyQuery, onProgress FuzzyProgress) ([]HotItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	dbInstance := m.mongoClient.Database(m.mongodbDatasName)
-	collectionNames, err := dbInstance.ListCollectionNames(ctx, bson.D{})
+	allCollectionNames, err := dbInstance.ListCollectionNames(ctx, bson.D{})
 	if err != nil {
 		return nil, fmt.Errorf("获取集合列表失败: %w", err)
 	}
+	var collectionNames []string
+	for _, name := range allCollectionNames {
+		if query.matchesSource(name) {
+			collectionNames = append(collectionNames, name)
+		}
+	}
+	if len(collectionNames) == 0 {
+		if onProgress != nil {
+			onProgress(0, 0, "")
+		}
+		return []HotItem{}, nil
+	}
 
 	// 并行查询通道
-	resultChan := make(chan []bson.M, len(collectionNames))
+	resultChan := make(chan []HotItem, len(collectionNames))
 	errChan := make(chan error, len(collectionNames))
 	var wg sync.WaitGroup
 
+	// 每完成一个集合（无论成功失败）上报一次进度，加锁保证 done 单调递增
+	var progressMu sync.Mutex
+	var done int
+	reportProgress := func(name string) {
+		if onProgress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		done++
+		onProgress(done, len(collectionNames), name)
+	}
+
 	// 为每个集合启动goroutine进行并行聚合查询
 	for _, collName := range collectionNames {
 		wg.Add(1)
 		go func(name string) {
 			defer wg.Done()
+			defer reportProgress(name)
 			coll := dbInstance.Collection(name)
-			// 查询单个集合，返回 []bson.M
-			results, err := querySingleCollection(ctx, coll, keyword)
+			results, err := querySingleCollection(ctx, coll, m.search, query)
 			if err != nil {
 				errChan <- fmt.Errorf("集合 %s 查询失败: %w", name, err)
 				return
@@ -60,41 +86,63 @@ func (m *MongoManger) GetMongoDataFuzzyByKeyword(keyword string) (interface{}, e
 	close(resultChan)
 	close(errChan)
 
-	// 检查并行查询中的错误（这里选择记录但继续，你也可以选择立即返回错误）
+	// 检查并行查询中的错误（部分集合失败时记录但继续，全部失败时返回错误以便消费者重试）
+	var failed int
+	var lastErr error
 	for err := range errChan {
 		// 记录到日志，不中断主流程
 		slog.Error("并行查询时发生错误", "error", err)
+		failed++
+		lastErr = err
+	}
+	if failed > 0 && failed == len(collectionNames) {
+		return nil, fmt.Errorf("所有集合查询均失败: %w", lastErr)
 	}
 
 	// 合并所有集合的查询结果
-	var allResults []bson.M
+	var allResults []HotItem
 	for results := range resultChan {
 		allResults = append(allResults, results...)
 	}
 
-	// 全局去重、排序和限制
-	finalResults := deduplicateAndSort(allResults, 50)
+	// 全局去重（按时间倒序）后按检索条件排序并限制数量
+	finalResults := deduplicateAndSort(allResults, len(allResults))
+	sortFuzzyResults(finalResults, query)
+	if len(finalResults) > MaxFuzzyResults {
+		finalResults = finalResults[:MaxFuzzyResults]
+	}
 	return finalResults, nil
 }
 
-// querySingleCollection 查询单个集合，返回泛化的文档列表
-func querySingleCollection(ctx context.Context, coll *mongo.Collection, keyword string) ([]bson.M, error) {
+// querySingleCollection 查询单个集合，结构不符合预期的文档（如缺少标题）被跳过
+// 每个集合按检索的排序方式取前 MaxFuzzyResults 条，合并后的全局前 MaxFuzzyResults 条必然在其中
+func querySingleCollection(ctx context.Context, coll *mongo.Collection, search SearchStrategy, query FuzzyQuery) ([]HotItem, error) {
+	// 同一标题只保留最新的一条（同一标题的相关度相同）
+	latestFirst := bson.D{{Key: crawledAtField, Value: -1}}
+	match := search.Match(query.Keyword)
+	if timeRange := query.timeRange(); timeRange != nil {
+		match = append(match, bson.E{Key: crawledAtField, Value: timeRange})
+	}
 	// MongoDB聚合管道定义
 	pipeline := mongo.Pipeline{
-		// 1. 匹配：hotitem.title 字段包含 keyword（不区分大小写）
+		// 1. 匹配：按检索策略匹配 hotitem.title（关键词按字面量处理）及爬取时间范围
 		{
-			{Key: "$match", Value: bson.D{
-				{Key: "hotitem.title", Value: bson.D{
-					{Key: "$regex", Value: keyword},
-					{Key: "$options", Value: "i"},
-				}},
-			}},
+			{Key: "$match", Value: match},
+		},
+	}
+	if search.Ranked() {
+		pipeline = append(pipeline, bson.D{
+			{Key: "$addFields", Value: bson.D{{Key: searchScoreField, Value: bson.D{{Key: "$meta", Value: "textScore"}}}}},
+		})
+	}
+	pipeline = append(pipeline, mongo.Pipeline{
+		// 隐藏检索辅助字段
+		{
+			{Key: "$project", Value: bson.D{{Key: titleNgramsField, Value: 0}}},
 		},
 		// 2. 按标题分组去重，并保留每组中crawledat最新的文档
 		{
-			{Key: "$sort", Value: bson.D{
-				{Key: "hotitem.crawledat", Value: -1},
-			}},
+			{Key: "$sort", Value: latestFirst},
 		},
 		{
 			{Key: "$group", Value: bson.D{
@@ -111,16 +159,18 @@ func querySingleCollection(ctx context.Context, coll *mongo.Collection, keyword
 				{Key: "newRoot", Value: "$doc"},
 			}},
 		},
-		// 4. 再次按时间倒序排序（针对去重后的结果）
-		{
-			{Key: "$sort", Value: bson.D{
-				{Key: "hotitem.crawledat", Value: -1},
-			}},
-		},
-		// 5. 限制单个集合返回的文档数量
-		{
-			{Key: "$limit", Value: 100},
-		},
+	}...)
+	// 4. 按检索的排序方式排序（热度、估算相关度先计算排序值），再限制单个集合返回的文档数量
+	sortKey, sortStage := fuzzySortStages(search, query)
+	if sortKey != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.D{{Key: fuzzySortKeyField, Value: sortKey}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: sortStage}},
+		bson.D{{Key: "$limit", Value: MaxFuzzyResults}},
+	)
+	if sortKey != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.D{{Key: fuzzySortKeyField, Value: 0}}}})
 	}
 	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -128,70 +178,49 @@ func querySingleCollection(ctx context.Context, coll *mongo.Collection, keyword
 	}
 	defer cursor.Close(ctx)
 
-	// 解码为通用的 bson.M 列表
-	var results []bson.M
-	if err = cursor.All(ctx, &results); err != nil {
+	// 先解码为通用的 bson.M，再按兼容各版本字段名的规则转换为 HotItem
+	var docs []bson.M
+	if err = cursor.All(ctx, &docs); err != nil {
 		return nil, err
 	}
-	return results, nil
-}
-
-// deduplicateAndSort 对泛化文档进行去重、排序和数量限制
-func deduplicateAndSort(docs []bson.M, limit int) []bson.M {
-	// 用于去重的map，key为title，value为文档
-	seen := make(map[string]bson.M)
-
+	results := make([]HotItem, 0, len(docs))
+	var skipped int
 	for _, doc := range docs {
-		// 从嵌套的 hotitem 字段中提取 title 和 crawledat
-		hotitem, ok := doc["hotitem"].(bson.M)
-		if !ok {
-			continue // 如果文档结构不符合预期，跳过
-		}
-
-		title, titleOk := hotitem["title"].(string)
-		crawledAtRaw, timeOk := hotitem["crawledat"].(primitive.DateTime) // MongoDB中的时间类型
-
-		if !titleOk || !timeOk {
-			continue // 如果关键字段缺失或类型不对，跳过
+		item, err := decodeHotItem(doc, coll.Name())
+		if err != nil {
+			skipped++
+			continue
 		}
+		results = append(results, item)
+	}
+	if skipped > 0 {
+		slog.Warn("跳过结构异常的文档", "collection", coll.Name(), "count", skipped)
+	}
+	return results, nil
+}
 
-		crawledAt := time.Unix(int64(crawledAtRaw)/1000, 0) // 转换为Go的time.Time
-
-		// 去重逻辑：如果该标题还未出现，或新文档的爬取时间更晚，则更新
-		existingDoc, exists := seen[title]
+// deduplicateAndSort 按标题去重（保留爬取时间最新的条目），按爬取时间倒序排序并限制数量
+func deduplicateAndSort(items []HotItem, limit int) []HotItem {
+	seen := make(map[string]int, len(items)) // title → uniqueItems 下标
+	uniqueItems := make([]HotItem, 0, len(items))
+	for _, item := range items {
+		idx, exists := seen[item.Title]
 		if !exists {
-			seen[title] = doc
+			seen[item.Title] = len(uniqueItems)
+			uniqueItems = append(uniqueItems, item)
 			continue
 		}
-
-		// 比较时间
-		existingHotitem := existingDoc["hotitem"].(bson.M)
-		existingCrawledAtRaw := existingHotitem["crawledat"].(primitive.DateTime)
-		existingCrawledAt := time.Unix(int64(existingCrawledAtRaw)/1000, 0)
-
-		if crawledAt.After(existingCrawledAt) {
-			seen[title] = doc
+		if item.CrawledAt.After(uniqueItems[idx].CrawledAt) {
+			uniqueItems[idx] = item
 		}
 	}
 
-	// 转换为切片，准备排序
-	uniqueDocs := make([]bson.M, 0, len(seen))
-	for _, doc := range seen {
-		uniqueDocs = append(uniqueDocs, doc)
-	}
-
-	// 按 hotitem.crawledat 倒序排序
-	sort.Slice(uniqueDocs, func(i, j int) bool {
-		hotitemI := uniqueDocs[i]["hotitem"].(bson.M)
-		hotitemJ := uniqueDocs[j]["hotitem"].(bson.M)
-		timeI := hotitemI["crawledat"].(primitive.DateTime)
-		timeJ := hotitemJ["crawledat"].(primitive.DateTime)
-		return timeI > timeJ // primitive.DateTime 可直接比较大小
+	sort.SliceStable(uniqueItems, func(i, j int) bool {
+		return uniqueItems[i].CrawledAt.After(uniqueItems[j].CrawledAt)
 	})
 
-	// 限制最终返回数量
-	if len(uniqueDocs) > limit {
-		return uniqueDocs[:limit]
+	if len(uniqueItems) > limit {
+		return uniqueItems[:limit]
 	}
-	return uniqueDocs
+	return uniqueItems
 }
@@ -0,0 +1,264 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 读路由策略名称（配置 redis_read.policy）
+const (
+	ReadPolicyRandom       = "random"        // 随机从节点
+	ReadPolicyRoundRobin   = "round_robin"   // 轮询从节点
+	ReadPolicyLeastLatency = "least_latency" // 探测延迟最低的从节点
+	ReadPolicyLagBounded   = "lag_bounded"   // 复制延迟不超过 max_lag_bytes 的从节点
+	ReadPolicyMasterOnly   = "master_only"   // 只读主节点（读己之写）
+)
+
+// ReadOptions 从节点读路由与熔断参数
+type ReadOptions struct {
+	Policy           string
+	MaxLagBytes      int64         // lag_bounded 策略允许的最大复制偏移差
+	ProbeInterval    time.Duration // 延迟/复制偏移探测间隔
+	FailureThreshold int           // 连续失败多少次后熔断
+	OpenDuration     time.Duration // 熔断持续时间
+}
+
+// DefaultReadOptions 默认读路由参数
+func DefaultReadOptions() ReadOptions {
+	return ReadOptions{
+		Policy:           ReadPolicyRandom,
+		MaxLagBytes:      1024,
+		ProbeInterval:    2 * time.Second,
+		FailureThreshold: 3,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// replica 从节点客户端及其健康状态
+type replica struct {
+	addr      string
+	client    *redis.Client
+	latency   atomic.Int64 // 探测延迟 EWMA（纳秒），0 表示未知
+	lag       atomic.Int64 // 复制偏移差（字节），-1 表示未知
+	failures  atomic.Int32 // 连续失败次数
+	openUntil atomic.Int64 // 熔断截止时间（UnixNano），0 表示未熔断
+}
+
+func newReplica(addr string, client *redis.Client) *replica {
+	rp := &replica{addr: addr, client: client}
+	rp.lag.Store(-1)
+	return rp
+}
+
+// available 熔断期内的从节点不参与读路由
+func (rp *replica) available(now time.Time) bool {
+	return now.UnixNano() >= rp.openUntil.Load()
+}
+
+// record 记录一次命令结果：连接/超时类错误累计失败次数，达到阈值后熔断
+// redis.Nil 和服务端返回的业务错误（如 WRONGTYPE）不视为节点故障；
+// 调用方取消或超时（长轮询、SSE 客户端断开）与节点健康无关，不计入也不清零失败次数
+func (rp *replica) record(err error, opts ReadOptions) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	var redisErr redis.Error
+	if err == nil || errors.Is(err, redis.Nil) || errors.As(err, &redisErr) {
+		rp.failures.Store(0)
+		rp.openUntil.Store(0)
+		return
+	}
+	if int(rp.failures.Add(1)) >= opts.FailureThreshold {
+		until := time.Now().Add(opts.OpenDuration)
+		if rp.openUntil.Swap(until.UnixNano()) < time.Now().UnixNano() {
+			slog.Warn("slave circuit opened", "addr", rp.addr, "until", until.Format(time.DateTime), "error", err)
+		}
+	}
+}
+
+// replicaHook 统计从节点命令结果，驱动熔断
+type replicaHook struct {
+	rp   *replica
+	opts ReadOptions
+}
+
+func (h replicaHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h replicaHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.rp.record(err, h.opts)
+		return err
+	}
+}
+
+func (h replicaHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h.rp.record(err, h.opts)
+		return err
+	}
+}
+
+// ReadPolicy 从可用从节点中选择一个，返回 nil 表示使用主节点
+type ReadPolicy interface {
+	Select(replicas []*replica) *replica
+}
+
+// NewReadPolicy 按名称创建读路由策略
+func NewReadPolicy(opts ReadOptions) (ReadPolicy, error) {
+	switch opts.Policy {
+	case ReadPolicyRandom, "":
+		return randomPolicy{}, nil
+	case ReadPolicyRoundRobin:
+		return &roundRobinPolicy{}, nil
+	case ReadPolicyLeastLatency:
+		return leastLatencyPolicy{}, nil
+	case ReadPolicyLagBounded:
+		return lagBoundedPolicy{maxLag: opts.MaxLagBytes}, nil
+	case ReadPolicyMasterOnly:
+		return masterOnlyPolicy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown read policy %q", opts.Policy)
+	}
+}
+
+type randomPolicy struct{}
+
+func (randomPolicy) Select(replicas []*replica) *replica {
+	if len(replicas) == 0 {
+		return nil
+	}
+	return replicas[rand.IntN(len(replicas))] // math/rand/v2 全局函数并发安全
+}
+
+type roundRobinPolicy struct {
+	next atomic.Uint64
+}
+
+func (p *roundRobinPolicy) Select(replicas []*replica) *replica {
+	if len(replicas) == 0 {
+		return nil
+	}
+	return replicas[p.next.Add(1)%uint64(len(replicas))]
+}
+
+type leastLatencyPolicy struct{}
+
+func (leastLatencyPolicy) Select(replicas []*replica) *replica {
+	var best *replica
+	var bestLatency int64
+	for _, rp := range replicas {
+		latency := rp.latency.Load()
+		if latency == 0 {
+			continue // 尚未探测
+		}
+		if best == nil || latency < bestLatency {
+			best, bestLatency = rp, latency
+		}
+	}
+	if best == nil {
+		return randomPolicy{}.Select(replicas)
+	}
+	return best
+}
+
+// lagBoundedPolicy 只在复制延迟已知且不超过阈值的从节点中随机选择，都不满足时读主节点
+type lagBoundedPolicy struct {
+	maxLag int64
+}
+
+func (p lagBoundedPolicy) Select(replicas []*replica) *replica {
+	candidates := make([]*replica, 0, len(replicas))
+	for _, rp := range replicas {
+		if lag := rp.lag.Load(); lag >= 0 && lag <= p.maxLag {
+			candidates = append(candidates, rp)
+		}
+	}
+	return randomPolicy{}.Select(candidates)
+}
+
+type masterOnlyPolicy struct{}
+
+func (masterOnlyPolicy) Select([]*replica) *replica {
+	return nil
+}
+
+// probeLoop 定期探测各从节点的延迟和复制偏移差（INFO replication）
+func (r *RedisManger) probeLoop(ctx context.Context) {
+	ticker := time.NewTicker(r.readOpts.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.probeReplicas(ctx)
+		}
+	}
+}
+
+func (r *RedisManger) probeReplicas(ctx context.Context) {
+	r.mu.RLock()
+	replicas := r.slaveClients
+	r.mu.RUnlock()
+	if len(replicas) == 0 {
+		return
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, r.readOpts.ProbeInterval)
+	defer cancel()
+
+	masterOffset := int64(-1)
+	if info, err := r.masterClient.Info(probeCtx, "replication").Result(); err == nil {
+		masterOffset = parseInfoInt(info, "master_repl_offset")
+	}
+
+	for _, rp := range replicas {
+		start := time.Now()
+		info, err := rp.client.Info(probeCtx, "replication").Result()
+		if err != nil {
+			rp.lag.Store(-1)
+			continue
+		}
+		sample := time.Since(start).Nanoseconds()
+		if old := rp.latency.Load(); old > 0 {
+			sample = (old*7 + sample*3) / 10 // EWMA 平滑
+		}
+		rp.latency.Store(sample)
+
+		slaveOffset := parseInfoInt(info, "slave_repl_offset")
+		if masterOffset >= 0 && slaveOffset >= 0 {
+			rp.lag.Store(max(masterOffset-slaveOffset, 0))
+		} else {
+			rp.lag.Store(-1)
+		}
+	}
+}
+
+// parseInfoInt 从 INFO 输出中解析整数字段，不存在返回 -1
+func parseInfoInt(info, field string) int64 {
+	for _, line := range strings.Split(info, "\n") {
+		value, ok := strings.CutPrefix(line, field+":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	return -1
+}
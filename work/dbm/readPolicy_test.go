@@ -0,0 +1,40 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestReplicaRecordOpensCircuit(t *testing.T) {
+	opts := ReadOptions{FailureThreshold: 2, OpenDuration: time.Minute}
+	netErr := errors.New("dial tcp 10.0.0.2:6379: connection refused")
+
+	for _, tc := range []struct {
+		name     string
+		errs     []error
+		wantOpen bool
+	}{
+		{"node failures", []error{netErr, netErr}, true},
+		{"success resets", []error{netErr, nil, netErr}, false},
+		{"redis nil is healthy", []error{netErr, redis.Nil, netErr}, false},
+		// 客户端断开导致的取消/超时不计入失败，也不清零已有的失败次数
+		{"canceled ignored", []error{context.Canceled, context.Canceled, context.Canceled}, false},
+		{"deadline ignored", []error{fmt.Errorf("hgetall: %w", context.DeadlineExceeded), netErr}, false},
+		{"context errors keep count", []error{netErr, context.Canceled, netErr}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rp := newReplica("10.0.0.2:6379", nil)
+			for _, err := range tc.errs {
+				rp.record(err, opts)
+			}
+			if open := !rp.available(time.Now()); open != tc.wantOpen {
+				t.Fatalf("circuit open = %v, want %v", open, tc.wantOpen)
+			}
+		})
+	}
+}
@@ -5,30 +5,34 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math/rand"
-	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisManger 管理 Redis 哨兵集群连接（v9 版本）
+// RedisManger 管理 Redis 连接（v9 版本），支持单节点、哨兵、集群三种部署
 type RedisManger struct {
-	masterClient *redis.Client          // 主节点客户端（仅用于写操作）
-	slaveClients []*redis.Client        // 从节点客户端列表（用于读操作）
+	masterClient redis.UniversalClient  // 写客户端（及需读己之写的读）：单节点/哨兵主节点/只访问主节点的集群客户端
+	readClient   redis.UniversalClient  // 普通读客户端（仅集群模式：ReadOnly 集群客户端，可路由到从节点）
+	slaveClients []*replica             // 从节点列表（仅哨兵模式，用于读操作）
 	maxBatches   int                    // 每个source最大数据批次数
-	sentinelOpts *redis.FailoverOptions // 哨兵配置（用于刷新主从节点）
+	sentinelOpts *redis.FailoverOptions // 哨兵配置（用于刷新主从节点，非哨兵模式为 nil）
+	translate    AddrTranslator         // 节点地址转换（哨兵返回地址 → 可拨号地址）
+	tracker      *replicaTracker        // 从节点跟踪器（仅哨兵模式）
 	mu           sync.RWMutex           // 保护从节点列表的并发安全
-	rand         *rand.Rand             // 用于随机选择从节点
+	readOpts     ReadOptions            // 读路由与熔断参数
+	readPolicy   ReadPolicy             // 从节点读路由策略
+	onMaster     atomic.Bool            // 当前是否因无可用从节点而降级读主节点（仅在状态变化时记录日志）
 }
 
-func (r *RedisManger) GetMasterClient() *redis.Client {
+func (r *RedisManger) GetMasterClient() redis.UniversalClient {
 
 	return r.masterClient
 }
 
-func (r *RedisManger) GetSlaveClient() (*redis.Client, error) {
+func (r *RedisManger) GetSlaveClient() (redis.UniversalClient, error) {
 	readclient, err := r.selectReadClient()
 	if err != nil {
 		return nil, fmt.Errorf("get readClinet fail %w", err)
@@ -36,283 +40,337 @@ func (r *RedisManger) GetSlaveClient() (*redis.Client, error) {
 	return readclient, nil
 }
 
-// NewRedisManager 初始化 RedisManager（哨兵集群+读写分离）
-func NewRedisManager(sentinelOpts *redis.FailoverOptions, maxBatches int) (*RedisManger, error) {
-	// 1. 创建主节点客户端
-	masterClient := redis.NewFailoverClient(sentinelOpts)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := masterClient.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("connect to master failed: %w", err)
-	}
+// AddrTranslator 将哨兵返回的节点地址转换为实际拨号地址（NAT、容器端口映射等场景）
+type AddrTranslator func(addr string) string
 
-	// 2. 获取从节点地址
-	slaveAddrs, err := getSlaveAddrs(sentinelOpts)
-	if err != nil {
-		return nil, fmt.Errorf("get slave addresses failed: %w", err)
+// NewAddrMapTranslator 基于地址映射表的转换器，未命中的地址原样返回
+func NewAddrMapTranslator(addrMap map[string]string) AddrTranslator {
+	return func(addr string) string {
+		if mapped, ok := addrMap[addr]; ok {
+			return mapped
+		}
+		return addr
 	}
+}
 
-	// 3. 为每个从节点创建客户端
-	var slaveClients []*redis.Client
-	for _, addr := range slaveAddrs {
+// NewStandaloneRedisManager 初始化单节点 RedisManager（读写均使用同一节点，适合本地开发）
+func NewStandaloneRedisManager(opts *redis.Options, maxBatches int) (*RedisManger, error) {
+	return newDirectRedisManager(redis.NewClient(opts), nil, maxBatches, "standalone")
+}
+
+// NewClusterRedisManager 初始化 Redis Cluster 的 RedisManager
+// 普通读请求由集群客户端按 opts 的 ReadOnly/RouteRandomly 配置路由到从节点；
+// 写入、分布式锁和需读己之写的读（GetHashConsistent 等）使用另一个只访问主节点的集群客户端
+func NewClusterRedisManager(opts *redis.ClusterOptions, maxBatches int) (*RedisManger, error) {
+	masterOpts := *opts
+	masterOpts.ReadOnly = false
+	masterOpts.RouteByLatency = false
+	masterOpts.RouteRandomly = false
+	return newDirectRedisManager(redis.NewClusterClient(&masterOpts), redis.NewClusterClient(opts), maxBatches, "cluster")
+}
 
-		switch addr {
-		case "172.28.0.10:6379": // 原主节点 → 主机端口 6379
-			addr = "localhost:6379"
-		case "172.28.0.11:6379": // 从节点1 → 主机端口 6380
-			addr = "localhost:6380"
-		case "172.28.0.12:6379": // 从节点2 → 主机端口 6381
-			addr = "localhost:6381"
+// newDirectRedisManager 非哨兵模式：无需刷新从节点，readClient 为 nil 时读写共用一个客户端
+func newDirectRedisManager(client, readClient redis.UniversalClient, maxBatches int, mode string) (*RedisManger, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	closeAll := func() {
+		client.Close()
+		if readClient != nil {
+			readClient.Close()
 		}
-		client := redis.NewClient(&redis.Options{
-			Addr:     addr,
-			Password: sentinelOpts.Password,
-			DB:       sentinelOpts.DB,
-		})
-		// 验证连接
-		if err := client.Ping(context.Background()).Err(); err != nil {
-			client.Close()
-			continue // 跳过不可用从节点
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("connect to redis (%s) failed: %w", mode, err)
+	}
+	if readClient != nil {
+		if err := readClient.Ping(ctx).Err(); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("connect to redis (%s) read client failed: %w", mode, err)
 		}
-		slaveClients = append(slaveClients, client)
 	}
+	slog.Info("RedisManager initialized", "mode", mode)
+	return &RedisManger{
+		masterClient: client,
+		readClient:   readClient,
+		maxBatches:   maxBatches,
+		translate:    NewAddrMapTranslator(nil),
+		readOpts:     DefaultReadOptions(),
+		readPolicy:   masterOnlyPolicy{},
+	}, nil
+}
 
-	if len(slaveClients) == 0 {
-		slog.Warn("no available slave nodes")
+// NewRedisManager 初始化 RedisManager（哨兵集群+读写分离）
+// translate 为 nil 时不做地址转换；readOpts 指定从节点读路由策略和熔断参数
+func NewRedisManager(sentinelOpts *redis.FailoverOptions, maxBatches int, translate AddrTranslator, readOpts ReadOptions) (*RedisManger, error) {
+	if translate == nil {
+		translate = NewAddrMapTranslator(nil)
+	}
+	readPolicy, err := NewReadPolicy(readOpts)
+	if err != nil {
+		return nil, err
 	}
-	slog.Info("RedisManager: 1 master", "slave_count", len(slaveClients))
+	// 1. 创建主节点客户端
+	masterClient := redis.NewFailoverClient(sentinelOpts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := masterClient.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("connect to master failed: %w", err)
+	}
+
 	rm := &RedisManger{
 		masterClient: masterClient,
-		slaveClients: slaveClients,
 		maxBatches:   maxBatches,
 		sentinelOpts: sentinelOpts,
-		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		translate:    translate,
+		readOpts:     readOpts,
+		readPolicy:   readPolicy,
 	}
 
-	// 4. 启动后台协程：定期刷新主从节点列表（每30秒，可调整）
-	go rm.refreshSlaveClientsLoop(30 * time.Second)
+	// 2. 通过哨兵获取从节点并创建客户端
+	tracker := newReplicaTracker(rm, sentinelOpts, map[string]*replica{})
+	tracker.sync(ctx)
+	rm.mu.RLock()
+	slaveCount := len(rm.slaveClients)
+	rm.mu.RUnlock()
+	if slaveCount == 0 {
+		slog.Warn("no available slave nodes")
+	}
+	slog.Info("RedisManager: 1 master", "slave_count", slaveCount)
+
+	// 3. 启动后台协程：订阅哨兵事件增量更新从节点列表，并定期探测延迟和复制偏移
+	rm.tracker = tracker
+	tracker.start()
 
 	return rm, nil
 
 }
 
-func (r *RedisManger) GetLatestDataBySource(ctx context.Context, source string) (interface{}, error) {
-	// 1. 获取该source的ZSet键
-	zsetKey := fmt.Sprintf("hot:zset:%s", source)
-	readClient, err := r.selectReadClient()
-	if err != nil {
-		return nil, fmt.Errorf("select readClient failed: %w", err)
-	}
-	// 2. 从ZSet中获取score最大的1个成员（最新数据键）
-	// ZREVRANGE：按score降序排列，取第0个（最新）
-	latestMembers, err := readClient.ZRevRange(ctx, zsetKey, 0, 0).Result()
+// GetLatestDataBySource 读取某数据源最新一批数据（兼容爬虫各版本的批次格式）
+func (r *RedisManger) GetLatestDataBySource(ctx context.Context, source string) (*HotBatch, error) {
+	batches, err := r.GetLatestDataBySources(ctx, []string{source})
 	if err != nil {
-		return nil, fmt.Errorf("get latest data key failed: %w", err)
+		return nil, err
 	}
-
-	// 3. 无数据时返回空
-	if len(latestMembers) == 0 {
+	batch, ok := batches[source]
+	if !ok {
 		return nil, fmt.Errorf("no data found for source: %s", source)
 	}
+	return batch, nil
+}
 
-	// 4. 根据数据键查询具体数据
-	latestDataKey := latestMembers[0]
-	jsonStr, err := readClient.Get(ctx, latestDataKey).Result()
+// GetLatestDataBySources 批量读取多个数据源的最新批次（两次流水线往返：先取各 ZSet 最新成员，再取数据）
+// 无数据或数据键已过期的数据源不出现在结果中
+func (r *RedisManger) GetLatestDataBySources(ctx context.Context, sources []string) (map[string]*HotBatch, error) {
+	readClient, err := r.selectReadClient()
 	if err != nil {
-		// 若数据键已过期（但ZSet未清理），删除ZSet中的无效成员
-		if err == redis.Nil {
-			_ = r.masterClient.ZRem(ctx, zsetKey, latestDataKey)
-			return nil, fmt.Errorf("latest data key expired: %s", latestDataKey)
-		}
-		return nil, fmt.Errorf("get data from redis failed: %w", err)
+		return nil, fmt.Errorf("select readClient failed: %w", err)
 	}
 
-	// 5. 反序列化为interface{}（或根据实际业务结构反序列化为具体结构体）
-	var data interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
-		return nil, fmt.Errorf("unmarshal json failed: %w", err)
+	// 1. 各 source 的 ZSet 中 score 最大的成员即最新数据键
+	pipe := readClient.Pipeline()
+	memberCmds := make([]*redis.ZSliceCmd, len(sources))
+	for i, source := range sources {
+		memberCmds[i] = pipe.ZRevRangeWithScores(ctx, hotZSetKey(source), 0, 0)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("get latest data keys failed: %w", err)
+	}
+	refs := make([]batchRef, 0, len(sources))
+	for i, cmd := range memberCmds {
+		members := cmd.Val()
+		if len(members) == 0 {
+			continue
+		}
+		refs = append(refs, newBatchRef(sources[i], members[0]))
 	}
 
-	return data, nil
+	// 2. 读取数据
+	batches, err := r.loadBatches(ctx, readClient, refs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*HotBatch, len(batches))
+	for _, batch := range batches {
+		result[batch.Source] = batch
+	}
+	return result, nil
 }
 
-func (r *RedisManger) GetDataByKey(ctx context.Context, cacheKey string) (interface{}, string, error) {
-
+// GetBatchHistory 按 score（爬取时间）倒序遍历某数据源 ZSet 中的批次，返回爬取时间在 [from, to] 内的批次
+// from/to 为零值表示不限；最多返回 maxBatches 个（ZSet 中保留的批次数）
+func (r *RedisManger) GetBatchHistory(ctx context.Context, source string, from, to time.Time) ([]*HotBatch, error) {
 	readClient, err := r.selectReadClient()
 	if err != nil {
-		return nil, "", fmt.Errorf("select readClient failed: %w", err)
+		return nil, fmt.Errorf("select readClient failed: %w", err)
+	}
+	members, err := readClient.ZRevRangeWithScores(ctx, hotZSetKey(source), 0, int64(max(r.maxBatches, 1))-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get batch keys failed: %w", err)
+	}
+	refs := make([]batchRef, 0, len(members))
+	for _, member := range members {
+		ref := newBatchRef(source, member)
+		if (from.IsZero() || !ref.scoreTime.Before(from)) && (to.IsZero() || !ref.scoreTime.After(to)) {
+			refs = append(refs, ref)
+		}
 	}
-	var data interface{}
-	cacheData, err := readClient.HGetAll(ctx, cacheKey).Result()
+	return r.loadBatches(ctx, readClient, refs)
+}
+
+// hotZSetKey 数据源批次索引（ZSet：member 为数据键，score 为爬取时间）
+func hotZSetKey(source string) string {
+	return fmt.Sprintf("hot:zset:%s", source)
+}
+
+// batchRef ZSet 中的一个批次
+type batchRef struct {
+	source    string
+	dataKey   string
+	scoreTime time.Time
+}
+
+func newBatchRef(source string, member redis.Z) batchRef {
+	dataKey, _ := member.Member.(string)
+	return batchRef{source: source, dataKey: dataKey, scoreTime: scoreToTime(member.Score)}
+}
 
-	if err == nil && len(cacheData) > 0 && cacheData["status"] == "ready" {
-		// 缓存命中，反序列化数据返回
-		json.Unmarshal([]byte(cacheData["data"]), &data)
-		return data, "", nil
+// scoreToTime ZSet score 转爬取时间（兼容 Unix 秒和毫秒）
+func scoreToTime(score float64) time.Time {
+	if score > 1e12 {
+		return time.UnixMilli(int64(score))
 	}
-	return nil, cacheData["status"], err
+	return time.Unix(int64(score), 0)
 }
 
-// GetAllSourcesLatestData 查询所有source的最新数据（可选）
-// func (r *RedisManger) GetAllSourcesLatestData(ctx context.Context) (map[string]interface{}, error) {
-// 	// 1. 模糊匹配所有source的ZSet键（hot:zset:*）
-// 	zsetKeys, err := r.masterClient.Keys(ctx, "hot:zset:*").Result()
-// 	if err != nil {
-// 		return nil, fmt.Errorf("list all zset keys failed: %w", err)
-// 	}
-
-// 	latestDataMap := make(map[string]interface{})
-// 	for _, zsetKey := range zsetKeys {
-// 		// 提取source名称（从hot:zset:source中截取）
-// 		source := zsetKey[len("hot:zset:"):]
-// 		// 查询该source的最新数据
-// 		data, err := r.GetLatestDataBySource(ctx, source)
-// 		if err != nil {
-// 			fmt.Printf("warn: get latest data for source %s failed: %v\n", source, err)
-// 			continue
-// 		}
-// 		latestDataMap[source] = data
-// 	}
-
-// 	return latestDataMap, nil
-// }
-
-// refreshSlaveClientsLoop 定期刷新从节点列表（应对主从切换）
-func (r *RedisManger) refreshSlaveClientsLoop(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		r.mu.Lock()
-		// 重新获取从节点列表
-		slaveAddrs, err := getSlaveAddrs(r.sentinelOpts)
-		if err != nil {
-			slog.Error("get slave addresses failed", "error", err)
-		}
+// loadBatches 流水线读取批次数据（保持 refs 顺序），已过期的数据键从 ZSet 中清理，无法解析的批次记录日志后跳过
+func (r *RedisManger) loadBatches(ctx context.Context, readClient redis.UniversalClient, refs []batchRef) ([]*HotBatch, error) {
+	if len(refs) == 0 {
+		return []*HotBatch{}, nil
+	}
+	pipe := readClient.Pipeline()
+	getCmds := make([]*redis.StringCmd, len(refs))
+	for i, ref := range refs {
+		getCmds[i] = pipe.Get(ctx, ref.dataKey)
+	}
+	// 部分键不存在时 Exec 返回 redis.Nil，逐条处理
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("get data from redis failed: %w", err)
+	}
 
-		// 3. 为每个从节点创建客户端
-		var slaveClients []*redis.Client
-		for _, addr := range slaveAddrs {
-			switch addr {
-			case "172.28.0.10:6379": // 原主节点 → 主机端口 6379
-				addr = "localhost:6379"
-			case "172.28.0.11:6379": // 从节点1 → 主机端口 6380
-				addr = "localhost:6380"
-			case "172.28.0.12:6379": // 从节点2 → 主机端口 6381
-				addr = "localhost:6381"
-			}
-			client := redis.NewClient(&redis.Options{
-				Addr:     addr,
-				Password: r.sentinelOpts.Password,
-				DB:       r.sentinelOpts.DB,
-			})
-			// 验证连接
-			if err := client.Ping(context.Background()).Err(); err != nil {
-				client.Close()
-				continue // 跳过不可用从节点
-			}
-			slaveClients = append(slaveClients, client)
+	batches := make([]*HotBatch, 0, len(refs))
+	for i, cmd := range getCmds {
+		ref := refs[i]
+		jsonStr, err := cmd.Result()
+		if err == redis.Nil {
+			// 数据键已过期（但 ZSet 未清理），删除 ZSet 中的无效成员
+			_ = r.masterClient.ZRem(ctx, hotZSetKey(ref.source), ref.dataKey)
+			continue
 		}
 		if err != nil {
-			slog.Error("refresh slave clients failed", "error", err)
-			r.mu.Unlock()
+			return nil, fmt.Errorf("get data from redis failed: %w", err)
+		}
+		var batch HotBatch
+		if err := json.Unmarshal([]byte(jsonStr), &batch); err != nil {
+			slog.Warn("解析批次数据失败", "key", ref.dataKey, "error", err)
 			continue
 		}
-
-		// 关闭旧的从节点客户端（避免资源泄漏）
-		for _, oldClient := range r.slaveClients {
-			_ = oldClient.Close()
+		// 以查询的数据源为准，批次内无时间时取 ZSet score
+		batch.Source = ref.source
+		if batch.CrawledAt.IsZero() {
+			batch.CrawledAt = ref.scoreTime
 		}
-
-		// 更新从节点客户端列表
-		r.slaveClients = slaveClients
-		r.mu.Unlock()
+		batch.fillDefaults()
+		batches = append(batches, &batch)
 	}
+	return batches, nil
 }
 
-// getSlaveAddrs 通过哨兵获取从节点地址列表
-func getSlaveAddrs(sentinelOpts *redis.FailoverOptions) ([]string, error) {
-	if len(sentinelOpts.SentinelAddrs) == 0 {
-		return nil, fmt.Errorf("sentinel address list is empty")
+// GetHash 读取哈希缓存（按读路由策略可能读从节点）
+func (r *RedisManger) GetHash(ctx context.Context, key string) (map[string]string, error) {
+	readClient, err := r.selectReadClient()
+	if err != nil {
+		return nil, fmt.Errorf("select readClient failed: %w", err)
 	}
+	return readClient.HGetAll(ctx, key).Result()
+}
 
-	// 连接第一个哨兵
-	sentinel := redis.NewClient(&redis.Options{
-		Addr: sentinelOpts.SentinelAddrs[0],
-	})
-	defer sentinel.Close()
+// GetHashConsistent 从主节点读取哈希缓存
+func (r *RedisManger) GetHashConsistent(ctx context.Context, key string) (map[string]string, error) {
+	return r.masterClient.HGetAll(ctx, key).Result()
+}
 
-	// 执行 SENTINEL SLAVES <master-name>
-	cmd := sentinel.Do(context.Background(), "SENTINEL", "SLAVES", sentinelOpts.MasterName)
-	if cmd.Err() != nil {
-		return nil, fmt.Errorf("SENTINEL SLAVES failed: %w", cmd.Err())
-	}
+// SetHash 写入哈希字段并设置过期时间
+func (r *RedisManger) SetHash(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error {
+	pipe := r.masterClient.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
 
-	// 解析返回值
-	slaves, ok := cmd.Val().([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid SENTINEL SLAVES response")
-	}
+// unlockScript 仅当锁的值与 token 一致时删除，避免误删他人持有的锁
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
 
-	var addrs []string
-	// 第二步：遍历每个从节点的 map 数据
-	for _, slaveRaw := range slaves {
-		// 将 slaveRaw 断言为 map[interface{}]interface{}（核心修正）
-		slaveMap, ok := slaveRaw.(map[interface{}]interface{})
-		if !ok {
-			slog.Warn("warning: invalid slave data format (not map), skip")
-			continue
-		}
+// TryLock 尝试获取分布式锁（SET NX + 过期时间）
+func (r *RedisManger) TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return r.masterClient.SetNX(ctx, key, token, ttl).Result()
+}
 
-		// 第三步：从 map 中提取 "ip" 和 "port"（key 是 string 类型）
-		ip := safeString(slaveMap["ip"])     // 按 key "ip" 取 IP 地址
-		port := safeString(slaveMap["port"]) // 按 key "port" 取端口号
+// Unlock 释放自己持有的分布式锁
+func (r *RedisManger) Unlock(ctx context.Context, key, token string) error {
+	return unlockScript.Run(ctx, r.masterClient, []string{key}, token).Err()
+}
 
-		// 验证 IP 和端口非空
-		if ip != "" && port != "" {
-			addr := net.JoinHostPort(ip, port)
-			addrs = append(addrs, addr)
-		} else {
-			slog.Warn("warning: slave node missing ip/port, skip", "ip", ip, "port", port)
+// selectReadClient 选择读操作的客户端（按读路由策略选择未熔断的从节点，无可用从节点则用主节点）
+func (r *RedisManger) selectReadClient() (redis.UniversalClient, error) {
+	r.mu.RLock()
+	replicas := r.slaveClients
+	r.mu.RUnlock()
+
+	now := time.Now()
+	available := make([]*replica, 0, len(replicas))
+	for _, rp := range replicas {
+		if rp.available(now) {
+			available = append(available, rp)
 		}
 	}
-
-	if len(addrs) == 0 {
-		return nil, fmt.Errorf("no valid slave nodes found")
+	if rp := r.readPolicy.Select(available); rp != nil {
+		if r.onMaster.CompareAndSwap(true, false) {
+			slog.Info("slave clients available again, read from slaves")
+		}
+		return rp.client, nil
 	}
 
-	return addrs, nil
-}
-
-// safeString 安全地将 interface{} 转换为 string（处理 nil、非 string 类型）
-func safeString(v interface{}) string {
-	if v == nil {
-		return ""
+	// 集群模式由 ReadOnly 集群客户端负责从节点路由
+	if r.readClient != nil {
+		return r.readClient, nil
 	}
-	// 尝试直接转换为 string
-	if s, ok := v.(string); ok {
-		return s
+	// 无可用从节点（或策略要求读主节点）时，降级到主节点
+	if r.sentinelOpts != nil && len(available) == 0 && r.onMaster.CompareAndSwap(false, true) {
+		slog.Warn("no slave clients available, use master client for read")
 	}
-	// 若值是数字类型（如 float64，部分 Redis 版本可能返回数字端口），转为 string
-	if num, ok := v.(float64); ok {
-		return fmt.Sprintf("%.0f", num) // 端口是整数，去掉小数部分
-	}
-	// 其他类型直接返回空字符串
-	return ""
+	return r.masterClient, nil
 }
 
-// selectReadClient 选择读操作的客户端（随机选择从节点，无从节点则用主节点）
-func (r *RedisManger) selectReadClient() (*redis.Client, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// 若有从节点，随机选择一个
-	if len(r.slaveClients) > 0 {
-		idx := r.rand.Intn(len(r.slaveClients))
-		return r.slaveClients[idx], nil
+// Close 停止从节点跟踪并关闭全部客户端
+func (r *RedisManger) Close() {
+	if r.tracker != nil {
+		r.tracker.stop()
+	}
+	r.mu.Lock()
+	r.slaveClients = nil
+	r.mu.Unlock()
+	if err := r.masterClient.Close(); err != nil {
+		slog.Error("redisMaster clsoe fail", "error", err)
+	}
+	if r.readClient != nil {
+		if err := r.readClient.Close(); err != nil {
+			slog.Error("redis read client close fail", "error", err)
+		}
 	}
-
-	// 无从节点时，降级到主节点
-	slog.Warn("no slave clients available, use master client for read")
-	return r.masterClient, nil
 }
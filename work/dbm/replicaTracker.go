@@ -0,0 +1,262 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	replicaResyncInterval = 60 * time.Second // 无事件时的兜底全量同步间隔
+	replicaDrainDelay     = 10 * time.Second // 下线从节点客户端延迟关闭时间（等待使用中的请求完成）
+	sentinelRetryDelay    = 2 * time.Second  // 所有哨兵均不可用时的重试间隔
+)
+
+// sentinelEvents 需要关注的哨兵事件（主从切换、节点主观下线/恢复、新增从节点）
+var sentinelEvents = []string{"+switch-master", "+sdown", "-sdown", "+slave"}
+
+// replicaTracker 订阅哨兵事件，增量维护从节点客户端列表
+type replicaTracker struct {
+	rm      *RedisManger
+	opts    *redis.FailoverOptions
+	clients map[string]*replica // 可拨号地址 → 从节点
+	syncMu  sync.Mutex          // 串行化 sync，保护 clients
+
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup // 事件监听与健康探测协程
+	stopping chan struct{}  // 停止信号（用于提前结束排空等待）
+	draining sync.WaitGroup // 排空中的旧客户端
+}
+
+func newReplicaTracker(rm *RedisManger, opts *redis.FailoverOptions, initial map[string]*replica) *replicaTracker {
+	return &replicaTracker{
+		rm:       rm,
+		opts:     opts,
+		clients:  initial,
+		stopping: make(chan struct{}),
+	}
+}
+
+// start 启动事件监听和健康探测协程
+func (t *replicaTracker) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.workers.Add(2)
+	go func() {
+		defer t.workers.Done()
+		t.run(ctx)
+	}()
+	go func() {
+		defer t.workers.Done()
+		t.rm.probeLoop(ctx)
+	}()
+}
+
+// stop 停止监听，关闭全部从节点客户端（含排空中的旧客户端）
+func (t *replicaTracker) stop() {
+	t.cancel()
+	t.workers.Wait()
+	close(t.stopping)
+	t.draining.Wait()
+
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+	for addr, rp := range t.clients {
+		if err := rp.client.Close(); err != nil {
+			slog.Error("redisSlaves  clsoe fail", "addr", addr, "error", err)
+		}
+	}
+	t.clients = map[string]*replica{}
+}
+
+// run 依次尝试各个哨兵订阅事件，当前哨兵断开后切换到下一个
+func (t *replicaTracker) run(ctx context.Context) {
+	addrs := t.opts.SentinelAddrs
+	for i := 0; ; i = (i + 1) % len(addrs) {
+		err := t.watch(ctx, addrs[i])
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("sentinel subscription lost, switch to next sentinel", "sentinel", addrs[i], "error", err)
+		if i == len(addrs)-1 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sentinelRetryDelay):
+			}
+		}
+	}
+}
+
+// watch 订阅单个哨兵的事件，收到相关事件或超时兜底时同步从节点列表
+func (t *replicaTracker) watch(ctx context.Context, sentinelAddr string) error {
+	sentinel := t.newSentinelClient(sentinelAddr)
+	defer sentinel.Close()
+
+	pubsub := sentinel.Subscribe(ctx, sentinelEvents...)
+	defer pubsub.Close()
+	// ctx 取消时关闭订阅，打断阻塞中的读取
+	stopAfter := context.AfterFunc(ctx, func() { _ = pubsub.Close() })
+	defer stopAfter()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe failed: %w", err)
+	}
+	slog.Info("subscribed to sentinel events", "sentinel", sentinelAddr, "events", sentinelEvents)
+
+	// 订阅建立后全量同步一次，覆盖断开期间错过的事件
+	t.sync(ctx)
+
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, replicaResyncInterval)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.sync(ctx)
+				continue
+			}
+			return err
+		}
+		if m, ok := msg.(*redis.Message); ok && t.isRelevant(m.Payload) {
+			slog.Info("sentinel event received", "event", m.Channel, "payload", m.Payload)
+			t.sync(ctx)
+		}
+	}
+}
+
+// isRelevant 判断事件是否属于当前监控的主节点
+func (t *replicaTracker) isRelevant(payload string) bool {
+	return slices.Contains(strings.Fields(payload), t.opts.MasterName)
+}
+
+// sync 与哨兵返回的从节点列表做差异比较：只新建新增节点客户端，排空并关闭移除的节点
+func (t *replicaTracker) sync(ctx context.Context) {
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	addrs, err := discoverReplicas(ctx, t.opts)
+	if err != nil {
+		slog.Error("get slave addresses failed", "error", err)
+		return
+	}
+
+	wanted := make(map[string]bool, len(addrs))
+	next := make(map[string]*replica, len(addrs))
+	for _, addr := range addrs {
+		dialAddr := t.rm.translate(addr)
+		wanted[dialAddr] = true
+		if rp, ok := t.clients[dialAddr]; ok {
+			next[dialAddr] = rp
+			continue
+		}
+		// 新增从节点：验证连接后加入（ping 不持有 RedisManger 的锁）
+		client := redis.NewClient(&redis.Options{
+			Addr:     dialAddr,
+			Password: t.opts.Password,
+			DB:       t.opts.DB,
+		})
+		pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := client.Ping(pingCtx).Err()
+		cancel()
+		if err != nil {
+			slog.Warn("skip unavailable slave", "addr", dialAddr, "error", err)
+			_ = client.Close()
+			continue
+		}
+		rp := newReplica(dialAddr, client)
+		client.AddHook(replicaHook{rp: rp, opts: t.rm.readOpts})
+		next[dialAddr] = rp
+		slog.Info("slave client added", "addr", dialAddr)
+	}
+
+	var removed []*redis.Client
+	for addr, rp := range t.clients {
+		if !wanted[addr] {
+			removed = append(removed, rp.client)
+			slog.Info("slave client removed", "addr", addr)
+		}
+	}
+	t.clients = next
+
+	list := make([]*replica, 0, len(next))
+	for _, rp := range next {
+		list = append(list, rp)
+	}
+	t.rm.mu.Lock()
+	t.rm.slaveClients = list
+	t.rm.mu.Unlock()
+
+	for _, client := range removed {
+		t.drain(client)
+	}
+}
+
+// drain 延迟关闭已移除的客户端，给仍持有该客户端的请求留出完成时间
+func (t *replicaTracker) drain(client *redis.Client) {
+	t.draining.Add(1)
+	go func() {
+		defer t.draining.Done()
+		select {
+		case <-time.After(replicaDrainDelay):
+		case <-t.stopping:
+		}
+		_ = client.Close()
+	}()
+}
+
+func (t *replicaTracker) newSentinelClient(addr string) *redis.SentinelClient {
+	return redis.NewSentinelClient(&redis.Options{
+		Addr:     addr,
+		Username: t.opts.SentinelUsername,
+		Password: t.opts.SentinelPassword,
+	})
+}
+
+// discoverReplicas 依次询问各个哨兵，返回第一个成功响应中健康的从节点地址
+func discoverReplicas(ctx context.Context, opts *redis.FailoverOptions) ([]string, error) {
+	if len(opts.SentinelAddrs) == 0 {
+		return nil, fmt.Errorf("sentinel address list is empty")
+	}
+
+	var errs []error
+	for _, sentinelAddr := range opts.SentinelAddrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:     sentinelAddr,
+			Username: opts.SentinelUsername,
+			Password: opts.SentinelPassword,
+		})
+		queryCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		replicas, err := sentinel.Replicas(queryCtx, opts.MasterName).Result()
+		cancel()
+		_ = sentinel.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sentinel %s: %w", sentinelAddr, err))
+			continue
+		}
+
+		addrs := make([]string, 0, len(replicas))
+		for _, node := range replicas {
+			ip, port := node["ip"], node["port"]
+			if ip == "" || port == "" {
+				slog.Warn("warning: slave node missing ip/port, skip", "ip", ip, "port", port)
+				continue
+			}
+			// 跳过主观/客观下线或已断开的从节点
+			flags := node["flags"]
+			if strings.Contains(flags, "s_down") || strings.Contains(flags, "o_down") || strings.Contains(flags, "disconnected") {
+				continue
+			}
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("SENTINEL REPLICAS failed on all sentinels: %w", errors.Join(errs...))
+}
@@ -0,0 +1,260 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	titleField       = "hotitem.title"
+	titleNgramsField = "title_ngrams" // ngram 模式下为标题补齐的 n-gram 数组（顶层字段，不影响 hotitem 结构）
+	searchScoreField = "score"        // text 模式的相关度
+	backfillBatch    = 500
+)
+
+// SearchStrategy 模糊检索策略：决定 $match 条件、是否按相关度排序以及依赖的索引
+type SearchStrategy interface {
+	Name() string
+	// Match 构造关键词匹配条件（$match 阶段内容），关键词按字面量处理
+	Match(keyword string) bson.D
+	// Ranked 为 true 时结果带 score 字段并按相关度排序
+	Ranked() bool
+	// EnsureIndexes 创建策略依赖的索引（幂等）
+	EnsureIndexes(ctx context.Context, coll *mongo.Collection) error
+	// Backfill 为存量文档补齐检索字段（无需补齐的策略直接返回 nil）
+	Backfill(ctx context.Context, coll *mongo.Collection) error
+}
+
+// NewSearchStrategy 按配置 fuzzy_search 创建检索策略
+func NewSearchStrategy(cfg config.FuzzySearchConfig) (SearchStrategy, error) {
+	switch cfg.Strategy {
+	case config.SearchStrategyRegex, "":
+		return regexStrategy{}, nil
+	case config.SearchStrategyText:
+		return textStrategy{}, nil
+	case config.SearchStrategyNgram:
+		if cfg.NgramSize < 1 {
+			return nil, fmt.Errorf("invalid ngram size %d", cfg.NgramSize)
+		}
+		return ngramStrategy{size: cfg.NgramSize}, nil
+	default:
+		return nil, fmt.Errorf("unknown fuzzy search strategy %q", cfg.Strategy)
+	}
+}
+
+// regexStrategy 不区分大小写的包含匹配，关键词中的正则元字符被转义
+// 标题索引只能让 MongoDB 扫描索引键代替扫描文档，仍是线性开销
+type regexStrategy struct{}
+
+func (regexStrategy) Name() string { return config.SearchStrategyRegex }
+func (regexStrategy) Ranked() bool { return false }
+
+func (regexStrategy) Match(keyword string) bson.D {
+	return bson.D{{Key: titleField, Value: literalRegex(keyword)}}
+}
+
+func (regexStrategy) EnsureIndexes(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: titleField, Value: 1}}})
+	return err
+}
+
+func (regexStrategy) Backfill(context.Context, *mongo.Collection) error { return nil }
+
+// textStrategy $text 文本索引检索，多个词之间为“或”关系，按 textScore 排序
+// 使用 default_language=none 关闭词干处理；中文无空格分词，整句只能整体匹配
+type textStrategy struct{}
+
+func (textStrategy) Name() string { return config.SearchStrategyText }
+func (textStrategy) Ranked() bool { return true }
+
+func (textStrategy) Match(keyword string) bson.D {
+	// 去掉 $text 的短语（"）和排除（-）语法，按普通词检索
+	terms := strings.FieldsFunc(keyword, func(r rune) bool { return r == '"' || r == ' ' })
+	for i, term := range terms {
+		terms[i] = strings.TrimLeft(term, "-")
+	}
+	return bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: strings.Join(terms, " ")}}}}
+}
+
+func (textStrategy) EnsureIndexes(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: titleField, Value: "text"}},
+		Options: options.Index().SetName("hotitem_title_text").SetDefaultLanguage("none"),
+	})
+	return err
+}
+
+func (textStrategy) Backfill(context.Context, *mongo.Collection) error { return nil }
+
+// ngramStrategy 标题切分为长度 1..size 的 n-gram 存入多键索引，
+// 检索时要求关键词的全部 n-gram 命中索引，再用转义正则校验完整包含关系
+// 尚未补齐 n-gram 的新文档在下次 Backfill 前不会被检索到
+type ngramStrategy struct {
+	size int
+}
+
+func (s ngramStrategy) Name() string { return config.SearchStrategyNgram }
+func (s ngramStrategy) Ranked() bool { return false }
+
+func (s ngramStrategy) Match(keyword string) bson.D {
+	return bson.D{
+		{Key: titleNgramsField, Value: bson.D{{Key: "$all", Value: keywordNgrams(keyword, s.size)}}},
+		{Key: titleField, Value: literalRegex(keyword)},
+	}
+}
+
+func (s ngramStrategy) EnsureIndexes(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: titleNgramsField, Value: 1}}})
+	return err
+}
+
+// Backfill 为缺少 title_ngrams 的文档批量写入 n-gram（标题变化后需删除该字段以重新生成）
+func (s ngramStrategy) Backfill(ctx context.Context, coll *mongo.Collection) error {
+	cursor, err := coll.Find(ctx,
+		bson.D{
+			{Key: titleField, Value: bson.D{{Key: "$type", Value: "string"}}},
+			{Key: titleNgramsField, Value: bson.D{{Key: "$exists", Value: false}}},
+		},
+		options.Find().SetProjection(bson.D{{Key: titleField, Value: 1}}).SetBatchSize(backfillBatch),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var models []mongo.WriteModel
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		_, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		models = models[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID      interface{} `bson:"_id"`
+			HotItem struct {
+				Title string `bson:"title"`
+			} `bson:"hotitem"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: doc.ID}}).
+			SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: titleNgramsField, Value: titleNgrams(doc.HotItem.Title, s.size)}}}}))
+		if len(models) >= backfillBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// literalRegex 不区分大小写、按字面量匹配的正则（转义元字符，避免改变查询含义或构造灾难性回溯）
+func literalRegex(keyword string) bson.D {
+	return bson.D{
+		{Key: "$regex", Value: regexp.QuoteMeta(keyword)},
+		{Key: "$options", Value: "i"},
+	}
+}
+
+// titleNgrams 标题（小写）的全部长度 1..size 的 n-gram，去重
+func titleNgrams(title string, size int) []string {
+	runes := []rune(strings.ToLower(title))
+	seen := make(map[string]struct{})
+	grams := make([]string, 0, len(runes)*size)
+	for n := 1; n <= size; n++ {
+		for i := 0; i+n <= len(runes); i++ {
+			gram := string(runes[i : i+n])
+			if strings.TrimSpace(gram) == "" {
+				continue
+			}
+			if _, ok := seen[gram]; !ok {
+				seen[gram] = struct{}{}
+				grams = append(grams, gram)
+			}
+		}
+	}
+	return grams
+}
+
+// keywordNgrams 关键词用于检索的 n-gram：长度不足 size 时为关键词本身（标题已包含所有短 n-gram）
+// 与 titleNgrams 一致跳过纯空白的 n-gram（标题中不存储，否则含空格的关键词永远无法命中）
+func keywordNgrams(keyword string, size int) []string {
+	runes := []rune(strings.ToLower(keyword))
+	if len(runes) <= size {
+		return []string{string(runes)}
+	}
+	grams := make([]string, 0, len(runes)-size+1)
+	for i := 0; i+size <= len(runes); i++ {
+		gram := string(runes[i : i+size])
+		if strings.TrimSpace(gram) == "" {
+			continue
+		}
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+// EnsureSearchIndexes 为数据库中所有数据集合创建检索索引和走势查询索引（新数据源的集合在下次调用时补建）
+func (m *MongoManger) EnsureSearchIndexes(ctx context.Context) error {
+	return m.eachDataCollection(ctx, func(ctx context.Context, coll *mongo.Collection) error {
+		return errors.Join(m.search.EnsureIndexes(ctx, coll), ensureTrendIndex(ctx, coll))
+	})
+}
+
+// BackfillSearchFields 为所有数据集合补齐检索字段
+func (m *MongoManger) BackfillSearchFields(ctx context.Context) error {
+	return m.eachDataCollection(ctx, m.search.Backfill)
+}
+
+// MaintainSearchIndexes 定期为新集合建索引并补齐检索字段（启动后立即执行一次），ctx 取消后退出
+func (m *MongoManger) MaintainSearchIndexes(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.EnsureSearchIndexes(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("创建模糊检索索引失败", "strategy", m.search.Name(), "error", err)
+		}
+		if err := m.BackfillSearchFields(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("补齐模糊检索字段失败", "strategy", m.search.Name(), "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *MongoManger) eachDataCollection(ctx context.Context, fn func(context.Context, *mongo.Collection) error) error {
+	dbInstance := m.mongoClient.Database(m.mongodbDatasName)
+	collectionNames, err := dbInstance.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("获取集合列表失败: %w", err)
+	}
+	var errs []error
+	for _, name := range collectionNames {
+		if err := fn(ctx, dbInstance.Collection(name)); err != nil {
+			errs = append(errs, fmt.Errorf("集合 %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
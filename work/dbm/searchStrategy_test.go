@@ -0,0 +1,28 @@
+package dbm
+
+import (
+	"slices"
+	"testing"
+)
+
+// 关键词的每个 n-gram 都必须出现在包含该关键词的标题的 n-gram 中，否则 $all 永远不会命中
+func TestKeywordNgramsSubsetOfTitleNgrams(t *testing.T) {
+	cases := []struct {
+		title, keyword string
+	}{
+		{"Hello A B World", "a b"},
+		{"Go 1.24 正式发布", "go 1.24"},
+		{"热搜 第一 条", "搜 第"},
+		{"双  空格", "双  空"},
+	}
+	for _, tc := range cases {
+		for size := 1; size <= 4; size++ {
+			title := titleNgrams(tc.title, size)
+			for _, gram := range keywordNgrams(tc.keyword, size) {
+				if !slices.Contains(title, gram) {
+					t.Errorf("size %d: keyword %q gram %q missing from title %q ngrams", size, tc.keyword, gram, tc.title)
+				}
+			}
+		}
+	}
+}
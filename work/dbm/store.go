@@ -0,0 +1,91 @@
+package dbm
+
+import (
+	"context"
+	"time"
+)
+
+// 存储接口：handle、until 只依赖这些接口，由 main 注入 Redis/MongoDB 实现，
+// 测试或单机运行时可注入 MemoryStore
+
+// HotDataStore 各数据源的爬取批次数据
+type HotDataStore interface {
+	GetLatestDataBySource(ctx context.Context, source string) (*HotBatch, error)
+	// GetLatestDataBySources 批量读取最新批次，无数据的数据源不出现在结果中
+	GetLatestDataBySources(ctx context.Context, sources []string) (map[string]*HotBatch, error)
+	// GetBatchHistory 爬取时间在 [from, to] 内的批次（零值表示不限），按时间倒序
+	GetBatchHistory(ctx context.Context, source string, from, to time.Time) ([]*HotBatch, error)
+}
+
+// FuzzyProgress 模糊检索进度回调：done/total 为已检索完成/全部集合数，collection 为刚完成的集合
+type FuzzyProgress func(done, total int, collection string)
+
+// FuzzySearchStore 按关键词模糊检索历史爬取数据（onProgress 可为 nil），结果已按 query.Sort 排序，ctx 取消时中止检索
+type FuzzySearchStore interface {
+	GetMongoDataFuzzyByKeyword(ctx context.Context, query FuzzyQuery, onProgress FuzzyProgress) ([]HotItem, error)
+}
+
+// TrendStore 单个条目在各数据源上的历史走势
+type TrendStore interface {
+	GetTitleTrend(ctx context.Context, query TrendQuery) ([]TrendSeries, error)
+}
+
+// CacheStore 哈希结构缓存（模糊查询结果、轮询请求状态）
+type CacheStore interface {
+	GetHash(ctx context.Context, key string) (map[string]string, error)           // 允许读从节点
+	GetHashConsistent(ctx context.Context, key string) (map[string]string, error) // 读主节点（读己之写）
+	SetHash(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error
+}
+
+// Locker 带过期时间的分布式锁，token 用于保证只释放自己持有的锁
+type Locker interface {
+	TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, key, token string) error
+}
+
+// EventBus 发布/订阅通知（模糊查询进度与完成事件），消息不持久化，订阅前发布的消息会丢失
+type EventBus interface {
+	PublishEvent(ctx context.Context, channel string, payload []byte) error
+	// SubscribeEvents 订阅频道，返回时订阅已生效；ctx 取消后退订并关闭返回的通道
+	SubscribeEvents(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// UserStore 用户账户
+type UserStore interface {
+	CreateUser(ctx context.Context, username, passwordHash, nickname string, roles []string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByID(ctx context.Context, userID uint64) (*User, error)
+	UpdateUserRoles(ctx context.Context, userID uint64, roles, permissions []string) (*User, error)
+}
+
+// SubscriptionStore 用户的关键词/数据源订阅
+type SubscriptionStore interface {
+	CreateSubscription(ctx context.Context, sub Subscription) error
+	CountSubscriptions(ctx context.Context, userID uint64) (int, error)
+	ListSubscriptions(ctx context.Context, userID uint64) ([]Subscription, error)
+	ListAllSubscriptions(ctx context.Context) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, userID uint64, id string) error // 不存在或不属于该用户时返回 ErrSubscriptionNotFound
+	// VerifySubscriptionEmail 校验邮箱验证码摘要，不匹配或已过期时返回 ErrEmailCodeInvalid
+	VerifySubscriptionEmail(ctx context.Context, userID uint64, id, codeHash string) error
+}
+
+// TokenStore 刷新令牌与访问令牌吊销列表
+type TokenStore interface {
+	SaveRefreshToken(ctx context.Context, tokenHash string, owner RefreshTokenOwner, ttl time.Duration) error
+	ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenOwner, error)
+	DeleteRefreshToken(ctx context.Context, tokenHash string) error
+	RevokeToken(ctx context.Context, jti string, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+var (
+	_ HotDataStore      = (*RedisManger)(nil)
+	_ CacheStore        = (*RedisManger)(nil)
+	_ Locker            = (*RedisManger)(nil)
+	_ TokenStore        = (*RedisManger)(nil)
+	_ EventBus          = (*RedisManger)(nil)
+	_ FuzzySearchStore  = (*MongoManger)(nil)
+	_ UserStore         = (*MongoManger)(nil)
+	_ TrendStore        = (*MongoManger)(nil)
+	_ SubscriptionStore = (*MongoManger)(nil)
+)
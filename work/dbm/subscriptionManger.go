@@ -0,0 +1,173 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const subscriptionsCollName = "subscriptions" // 订阅集合（mongodb_name_users 库）
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrEmailCodeInvalid     = errors.New("email verification code invalid or expired")
+)
+
+// 通知渠道
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Subscription 用户的关键词/数据源订阅：标题包含任一关键词（不区分大小写）且来自所选数据源的条目触发提醒
+// Keywords 为空表示订阅数据源的全部条目，Sources 为空表示全部数据源
+// 邮箱需用户提交验证邮件中的验证码后才会收到提醒，避免借发信账号向任意地址发信
+type Subscription struct {
+	ID            string    `bson:"_id" json:"id"`
+	UserID        uint64    `bson:"user_id" json:"userId"`
+	Keywords      []string  `bson:"keywords" json:"keywords"`
+	Sources       []string  `bson:"sources" json:"sources"`
+	Email         string    `bson:"email,omitempty" json:"email,omitempty"` // 提醒邮箱
+	EmailVerified bool      `bson:"email_verified" json:"emailVerified"`
+	EmailCodeHash string    `bson:"email_code_hash,omitempty" json:"-"`         // 邮箱验证码摘要
+	EmailCodeExp  time.Time `bson:"email_code_exp,omitempty" json:"-"`          // 验证码过期时间
+	Webhook       string    `bson:"webhook,omitempty" json:"webhook,omitempty"` // 提醒回调地址（POST JSON）
+	CreatedAt     time.Time `bson:"created_at" json:"createdAt"`
+}
+
+// NotifyTarget 一个通知渠道及其地址
+type NotifyTarget struct {
+	Channel string
+	Address string
+}
+
+// Targets 订阅可用的通知渠道（邮箱未验证时不发送）
+func (s Subscription) Targets() []NotifyTarget {
+	var targets []NotifyTarget
+	if s.Email != "" && s.EmailVerified {
+		targets = append(targets, NotifyTarget{Channel: ChannelEmail, Address: s.Email})
+	}
+	if s.Webhook != "" {
+		targets = append(targets, NotifyTarget{Channel: ChannelWebhook, Address: s.Webhook})
+	}
+	return targets
+}
+
+// Matches 条目是否命中订阅
+func (s Subscription) Matches(item HotItem) bool {
+	if len(s.Sources) > 0 && !slices.Contains(s.Sources, strings.ToLower(item.Source)) {
+		return false
+	}
+	if len(s.Keywords) == 0 {
+		return true
+	}
+	title := strings.ToLower(item.Title)
+	for _, keyword := range s.Keywords {
+		if strings.Contains(title, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSubscriptionID 生成订阅 ID
+func NewSubscriptionID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+func (m *MongoManger) subscriptionsColl() *mongo.Collection {
+	return m.mongoClient.Database(m.mongodbUsersName).Collection(subscriptionsCollName)
+}
+
+// EnsureSubscriptionIndexes 创建订阅集合索引（按用户查询）
+func (m *MongoManger) EnsureSubscriptionIndexes(ctx context.Context) error {
+	_, err := m.subscriptionsColl().Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("create subscription indexes failed: %w", err)
+	}
+	return nil
+}
+
+// CreateSubscription 保存订阅（ID 和创建时间由调用方生成）
+func (m *MongoManger) CreateSubscription(ctx context.Context, sub Subscription) error {
+	if _, err := m.subscriptionsColl().InsertOne(ctx, sub); err != nil {
+		return fmt.Errorf("insert subscription failed: %w", err)
+	}
+	return nil
+}
+
+// CountSubscriptions 用户的订阅数
+func (m *MongoManger) CountSubscriptions(ctx context.Context, userID uint64) (int, error) {
+	n, err := m.subscriptionsColl().CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("count subscriptions failed: %w", err)
+	}
+	return int(n), nil
+}
+
+// ListSubscriptions 用户的全部订阅（按创建时间）
+func (m *MongoManger) ListSubscriptions(ctx context.Context, userID uint64) ([]Subscription, error) {
+	return m.findSubscriptions(ctx, bson.M{"user_id": userID})
+}
+
+// ListAllSubscriptions 全部订阅（后台匹配使用）
+func (m *MongoManger) ListAllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	return m.findSubscriptions(ctx, bson.M{})
+}
+
+// DeleteSubscription 删除用户自己的订阅
+func (m *MongoManger) DeleteSubscription(ctx context.Context, userID uint64, id string) error {
+	result, err := m.subscriptionsColl().DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return fmt.Errorf("delete subscription failed: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// VerifySubscriptionEmail 验证码（摘要）匹配且未过期时将订阅邮箱标记为已验证
+func (m *MongoManger) VerifySubscriptionEmail(ctx context.Context, userID uint64, id, codeHash string) error {
+	result, err := m.subscriptionsColl().UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "email_code_hash": codeHash, "email_code_exp": bson.M{"$gt": time.Now()}},
+		bson.M{
+			"$set":   bson.M{"email_verified": true},
+			"$unset": bson.M{"email_code_hash": "", "email_code_exp": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("verify subscription email failed: %w", err)
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	n, err := m.subscriptionsColl().CountDocuments(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return fmt.Errorf("verify subscription email failed: %w", err)
+	}
+	if n == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return ErrEmailCodeInvalid
+}
+
+func (m *MongoManger) findSubscriptions(ctx context.Context, filter bson.M) ([]Subscription, error) {
+	cursor, err := m.subscriptionsColl().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("find subscriptions failed: %w", err)
+	}
+	subs := []Subscription{}
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, fmt.Errorf("decode subscriptions failed: %w", err)
+	}
+	return subs, nil
+}
@@ -0,0 +1,85 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshTokenPrefix = "auth:refresh:" // 刷新令牌（key 为令牌哈希，value 为所属用户）
+	revokedTokenPrefix = "auth:revoked:" // 已吊销的访问令牌 jti
+)
+
+var ErrRefreshTokenInvalid = errors.New("refresh token invalid or expired")
+
+// RefreshTokenOwner 刷新令牌所属用户
+type RefreshTokenOwner struct {
+	UserID   uint64
+	Username string
+}
+
+// SaveRefreshToken 保存刷新令牌（tokenHash 为令牌摘要，Redis 中不落明文）
+func (r *RedisManger) SaveRefreshToken(ctx context.Context, tokenHash string, owner RefreshTokenOwner, ttl time.Duration) error {
+	key := refreshTokenPrefix + tokenHash
+	pipe := r.masterClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", strconv.FormatUint(owner.UserID, 10),
+		"username", owner.Username,
+	)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("save refresh token failed: %w", err)
+	}
+	return nil
+}
+
+// ConsumeRefreshToken 取出并删除刷新令牌（一次性使用，保证轮换时旧令牌立即失效）
+func (r *RedisManger) ConsumeRefreshToken(ctx context.Context, tokenHash string) (*RefreshTokenOwner, error) {
+	key := refreshTokenPrefix + tokenHash
+	pipe := r.masterClient.TxPipeline()
+	getCmd := pipe.HGetAll(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("consume refresh token failed: %w", err)
+	}
+
+	fields := getCmd.Val()
+	if len(fields) == 0 {
+		return nil, ErrRefreshTokenInvalid
+	}
+	userID, err := strconv.ParseUint(fields["user_id"], 10, 64)
+	if err != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return &RefreshTokenOwner{UserID: userID, Username: fields["username"]}, nil
+}
+
+// DeleteRefreshToken 删除刷新令牌（登出时调用）
+func (r *RedisManger) DeleteRefreshToken(ctx context.Context, tokenHash string) error {
+	return r.masterClient.Del(ctx, refreshTokenPrefix+tokenHash).Err()
+}
+
+// RevokeToken 吊销访问令牌，记录保留到令牌原本的过期时间
+func (r *RedisManger) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil // 令牌已过期，无需记录
+	}
+	return r.masterClient.Set(ctx, revokedTokenPrefix+jti, "1", ttl).Err()
+}
+
+// IsTokenRevoked 判断访问令牌是否已被吊销（读主节点，避免从节点延迟导致吊销失效）
+func (r *RedisManger) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	err := r.masterClient.Get(ctx, revokedTokenPrefix+jti).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,159 @@
+package dbm
+
+import (
+	"cmp"
+	"slices"
+	"unicode"
+)
+
+// 话题聚类参数
+const (
+	topicNgramSize        = 2   // 标题字符 n-gram 长度
+	topicDiceThreshold    = 0.5 // Dice 系数达到该值视为同一话题
+	topicOverlapThreshold = 0.8 // 较短标题的 n-gram 大部分出现在较长标题中（如话题标签与完整标题）
+	topicOverlapMinGrams  = 4   // 按包含关系合并时较短标题至少的 n-gram 数，避免过短标题误合并
+	topicMaxPostings      = 200 // 出现在过多标题中的 n-gram 不用于召回候选（如“回应”“官方”）
+)
+
+// TopicCluster 跨数据源的同一话题
+type TopicCluster struct {
+	Title    string    `json:"title"`     // 代表标题（名次最高的条目）
+	Sources  []string  `json:"sources"`   // 涉及的数据源
+	BestRank int       `json:"best_rank"` // 各条目中的最高名次
+	HotValue float64   `json:"hot_value"` // 各条目热度之和
+	Items    []HotItem `json:"items"`
+}
+
+// ClusterTopics 将标题相近的条目聚为话题：标题规范化后取字符 n-gram，按名次从高到低依次处理，
+// 条目与已有话题的代表标题（话题中名次最高的条目）的 Dice 系数或包含度超过阈值即加入该话题，否则成为新话题的代表。
+// 只与代表标题比较，避免 A~B、B~C 的链式合并把不相关的 A、C 归为一组
+// 结果按涉及的数据源数、最高名次、总热度排序
+func ClusterTopics(items []HotItem) []TopicCluster {
+	grams := make([]map[string]struct{}, len(items))
+	for i, item := range items {
+		grams[i] = topicGrams(item.Title)
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return compareTopicItems(items[a], items[b]) })
+
+	// postings 只记录代表标题，通过共享 n-gram 召回候选话题
+	postings := make(map[string][]int)
+	var reps []int
+	groups := make(map[int][]HotItem)
+	for _, i := range order {
+		shared := make(map[int]int)
+		for gram := range grams[i] {
+			if len(postings[gram]) > topicMaxPostings {
+				continue
+			}
+			for _, rep := range postings[gram] {
+				shared[rep]++
+			}
+		}
+		best, bestScore := -1, 0.0
+		for rep, common := range shared {
+			if !similarTitles(common, len(grams[i]), len(grams[rep])) {
+				continue
+			}
+			score := 2 * float64(common) / float64(len(grams[i])+len(grams[rep]))
+			if score > bestScore || (score == bestScore && rep < best) {
+				best, bestScore = rep, score
+			}
+		}
+		if best < 0 {
+			best = i
+			reps = append(reps, i)
+			for gram := range grams[i] {
+				postings[gram] = append(postings[gram], i)
+			}
+		}
+		groups[best] = append(groups[best], items[i])
+	}
+
+	clusters := make([]TopicCluster, 0, len(reps))
+	for _, rep := range reps {
+		clusters = append(clusters, newTopicCluster(groups[rep]))
+	}
+	slices.SortFunc(clusters, func(a, b TopicCluster) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Sources), len(a.Sources)),
+			cmp.Compare(rankOrder(a.BestRank), rankOrder(b.BestRank)),
+			cmp.Compare(b.HotValue, a.HotValue),
+			cmp.Compare(a.Title, b.Title),
+		)
+	})
+	return clusters
+}
+
+// similarTitles 按共同 n-gram 数判断两条标题是否属于同一话题
+func similarTitles(common, sizeA, sizeB int) bool {
+	if sizeA == 0 || sizeB == 0 {
+		return false
+	}
+	if 2*float64(common)/float64(sizeA+sizeB) >= topicDiceThreshold {
+		return true
+	}
+	shorter := min(sizeA, sizeB)
+	return shorter >= topicOverlapMinGrams && float64(common)/float64(shorter) >= topicOverlapThreshold
+}
+
+// compareTopicItems 话题内条目的顺序：名次高的在前，名次相同时热度高的在前
+func compareTopicItems(a, b HotItem) int {
+	return cmp.Or(cmp.Compare(rankOrder(a.Rank), rankOrder(b.Rank)), cmp.Compare(b.HotValue, a.HotValue))
+}
+
+func newTopicCluster(members []HotItem) TopicCluster {
+	slices.SortStableFunc(members, compareTopicItems)
+	cluster := TopicCluster{Title: members[0].Title, BestRank: members[0].Rank, Items: members, Sources: []string{}}
+	for _, item := range members {
+		cluster.HotValue += item.HotValue
+		if item.Source != "" && !slices.Contains(cluster.Sources, item.Source) {
+			cluster.Sources = append(cluster.Sources, item.Source)
+		}
+	}
+	slices.Sort(cluster.Sources)
+	return cluster
+}
+
+// rankOrder 名次排序值（未知名次排在最后）
+func rankOrder(rank int) int {
+	if rank <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	return rank
+}
+
+// normalizeTopicTitle 规范化标题：全角转半角、小写，去除空白、标点和符号（如话题标签的 #）
+func normalizeTopicTitle(title string) []rune {
+	runes := make([]rune, 0, len(title))
+	for _, r := range title {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		runes = append(runes, unicode.ToLower(r))
+	}
+	return runes
+}
+
+// topicGrams 规范化标题的字符 n-gram 集合（短于 n 的标题整体作为一个 gram）
+func topicGrams(title string) map[string]struct{} {
+	runes := normalizeTopicTitle(title)
+	grams := make(map[string]struct{})
+	if len(runes) == 0 {
+		return grams
+	}
+	if len(runes) < topicNgramSize {
+		grams[string(runes)] = struct{}{}
+		return grams
+	}
+	for i := 0; i+topicNgramSize <= len(runes); i++ {
+		grams[string(runes[i:i+topicNgramSize])] = struct{}{}
+	}
+	return grams
+}
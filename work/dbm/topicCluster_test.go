@@ -0,0 +1,60 @@
+package dbm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClusterTopics(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		items []HotItem
+		want  [][]string // 各话题的条目标题（按聚类结果顺序）
+	}{
+		{
+			name: "cross source merge",
+			items: []HotItem{
+				{Source: "weibo", Title: "#杭州亚运会开幕#", Rank: 1},
+				{Source: "baidu", Title: "苹果发布新款手机", Rank: 2},
+				{Source: "zhihu", Title: "杭州亚运会开幕式今晚举行", Rank: 3},
+			},
+			want: [][]string{{"#杭州亚运会开幕#", "杭州亚运会开幕式今晚举行"}, {"苹果发布新款手机"}},
+		},
+		{
+			name: "unrelated titles stay apart",
+			items: []HotItem{
+				{Source: "weibo", Title: "杭州今日降雨", Rank: 1},
+				{Source: "zhihu", Title: "北京今日大风", Rank: 2},
+			},
+			want: [][]string{{"杭州今日降雨"}, {"北京今日大风"}},
+		},
+		{
+			// 甲~乙、乙~丙相似，但甲与丙无关：丙不能经由乙并入甲的话题
+			name: "no chaining through intermediate title",
+			items: []HotItem{
+				{Source: "weibo", Title: "甲乙丙丁戊己", Rank: 1},
+				{Source: "zhihu", Title: "丙丁戊己庚辛", Rank: 2},
+				{Source: "baidu", Title: "戊己庚辛壬癸", Rank: 3},
+			},
+			want: [][]string{{"甲乙丙丁戊己", "丙丁戊己庚辛"}, {"戊己庚辛壬癸"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clusters := ClusterTopics(tc.items)
+			got := make([][]string, 0, len(clusters))
+			for _, c := range clusters {
+				titles := make([]string, 0, len(c.Items))
+				for _, item := range c.Items {
+					titles = append(titles, item.Title)
+				}
+				if c.Title != titles[0] {
+					t.Fatalf("cluster title %q, want representative %q", c.Title, titles[0])
+				}
+				got = append(got, titles)
+			}
+			if !slices.EqualFunc(got, tc.want, slices.Equal) {
+				t.Fatalf("clusters = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
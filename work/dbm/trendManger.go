@@ -0,0 +1,183 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const crawledAtField = "hotitem.crawledat"
+
+// TrendQuery 单个条目的走势查询条件（标题精确匹配，兼容 hotItemAliases.title 中的历史字段名）
+type TrendQuery struct {
+	Title    string
+	Sources  []string       // 数据源（即集合名），为空表示全部
+	From     time.Time      // 爬取时间下限
+	To       time.Time      // 爬取时间上限
+	Interval time.Duration  // 分桶间隔
+	Location *time.Location // 分桶对齐的时区（interval=24h 时桶从当地零点开始），nil 表示 UTC
+}
+
+// bucketOffset 时区相对 UTC 的偏移（毫秒），按查询截止时间的偏移计算，不处理区间内的夏令时切换
+func (q TrendQuery) bucketOffset() int64 {
+	if q.Location == nil {
+		return 0
+	}
+	at := q.To
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, offset := at.In(q.Location).Zone()
+	return int64(offset) * 1000
+}
+
+// TrendPoint 一个时间桶内的统计（同一桶内有多次爬取时取最后一次作为名次和热度）
+type TrendPoint struct {
+	Time         time.Time `json:"time"`           // 桶起始时间
+	Rank         int       `json:"rank"`           // 桶内最后一次爬取的名次
+	BestRank     int       `json:"best_rank"`      // 桶内最高名次
+	HotValue     float64   `json:"hot_value"`      // 桶内最后一次爬取的热度
+	PeakHotValue float64   `json:"peak_hot_value"` // 桶内最高热度
+	Samples      int       `json:"samples"`        // 桶内爬取次数
+}
+
+// TrendSeries 某数据源上的走势（按时间升序，没有爬取到的时间桶不出现）
+type TrendSeries struct {
+	Source string       `json:"source"`
+	Points []TrendPoint `json:"points"`
+}
+
+// GetTitleTrend 按数据源聚合某标题各时间桶的名次与热度（无数据的数据源不出现在结果中）
+func (m *MongoManger) GetTitleTrend(ctx context.Context, query TrendQuery) ([]TrendSeries, error) {
+	if query.Interval < time.Millisecond {
+		return nil, errors.New("trend interval too small")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	dbInstance := m.mongoClient.Database(m.mongodbDatasName)
+	collectionNames, err := dbInstance.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("获取集合列表失败: %w", err)
+	}
+	slices.Sort(collectionNames)
+	series := []TrendSeries{}
+	for _, name := range collectionNames {
+		if len(query.Sources) > 0 && !slices.Contains(query.Sources, name) {
+			continue
+		}
+		points, err := queryCollectionTrend(ctx, dbInstance.Collection(name), query)
+		if err != nil {
+			return nil, fmt.Errorf("集合 %s 查询失败: %w", name, err)
+		}
+		if len(points) > 0 {
+			series = append(series, TrendSeries{Source: name, Points: points})
+		}
+	}
+	return series, nil
+}
+
+// queryCollectionTrend 聚合单个集合：按爬取时间分桶收集条目，名次和热度在解码后统计（兼容热度为文本的文档）
+func queryCollectionTrend(ctx context.Context, coll *mongo.Collection, query TrendQuery) ([]TrendPoint, error) {
+	titleMatch := make(bson.A, 0, len(hotItemAliases.title))
+	for _, alias := range hotItemAliases.title {
+		titleMatch = append(titleMatch, bson.D{{Key: "hotitem." + alias, Value: query.Title}})
+	}
+	match := bson.D{{Key: "$or", Value: titleMatch}}
+	timeRange := FuzzyQuery{From: query.From, To: query.To}.timeRange()
+	if timeRange != nil {
+		match = append(match, bson.E{Key: crawledAtField, Value: timeRange})
+	}
+	// 桶起始时间 = 爬取时间毫秒数 - (爬取时间毫秒数 + 时区偏移) % 间隔，即按当地时间对齐
+	crawledMillis := bson.D{{Key: "$toLong", Value: "$" + crawledAtField}}
+	localMillis := bson.D{{Key: "$add", Value: bson.A{crawledMillis, query.bucketOffset()}}}
+	bucket := bson.D{{Key: "$toDate", Value: bson.D{{Key: "$subtract", Value: bson.A{
+		crawledMillis,
+		bson.D{{Key: "$mod", Value: bson.A{localMillis, query.Interval.Milliseconds()}}},
+	}}}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: crawledAtField, Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bucket},
+			{Key: "items", Value: bson.D{{Key: "$push", Value: "$hotitem"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var buckets []struct {
+		Start time.Time `bson:"_id"`
+		Items []bson.M  `bson:"items"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+	points := make([]TrendPoint, 0, len(buckets))
+	for _, b := range buckets {
+		if b.Start.IsZero() {
+			continue // 缺少爬取时间的文档无法分桶
+		}
+		items := make([]HotItem, 0, len(b.Items))
+		for _, doc := range b.Items {
+			if item, err := decodeHotItem(doc, coll.Name()); err == nil {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			points = append(points, newTrendPoint(b.Start, items))
+		}
+	}
+	return points, nil
+}
+
+// trendBucket 爬取时间所在桶的起始时间（与聚合管道的分桶方式一致，offset 为时区偏移毫秒数）
+func trendBucket(t time.Time, interval time.Duration, offset int64) time.Time {
+	ms, step := t.UnixMilli(), interval.Milliseconds()
+	rem := (ms + offset) % step
+	if rem < 0 {
+		rem += step
+	}
+	return time.UnixMilli(ms - rem)
+}
+
+// newTrendPoint 统计一个桶（items 按爬取时间升序）
+func newTrendPoint(start time.Time, items []HotItem) TrendPoint {
+	last := items[len(items)-1]
+	point := TrendPoint{Time: start, Rank: last.Rank, HotValue: last.HotValue, Samples: len(items)}
+	for _, item := range items {
+		if item.Rank > 0 && (point.BestRank == 0 || item.Rank < point.BestRank) {
+			point.BestRank = item.Rank
+		}
+		point.PeakHotValue = max(point.PeakHotValue, item.HotValue)
+	}
+	return point
+}
+
+// ensureTrendIndex 走势查询按标题 + 爬取时间检索；历史标题字段名使用部分索引（只索引存在该字段的文档），
+// 使 $or 的每个分支都能走索引
+func ensureTrendIndex(ctx context.Context, coll *mongo.Collection) error {
+	models := []mongo.IndexModel{{Keys: bson.D{{Key: titleField, Value: 1}, {Key: crawledAtField, Value: 1}}}}
+	for _, alias := range hotItemAliases.title {
+		field := "hotitem." + alias
+		if field == titleField {
+			continue
+		}
+		models = append(models, mongo.IndexModel{
+			Keys:    bson.D{{Key: field, Value: 1}, {Key: crawledAtField, Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: true}}}}),
+		})
+	}
+	_, err := coll.Indexes().CreateMany(ctx, models)
+	return err
+}
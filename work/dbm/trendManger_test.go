@@ -0,0 +1,53 @@
+package dbm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrendBucketAlignsToLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	for _, tc := range []struct {
+		name     string
+		at       time.Time
+		interval time.Duration
+		loc      *time.Location
+		want     time.Time
+	}{
+		{"utc day", time.Date(2026, 3, 1, 5, 30, 0, 0, time.UTC), 24 * time.Hour, nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// 上海 3/1 07:30 对应 UTC 2/28 23:30，桶应从上海 3/1 零点开始
+		{"local day", time.Date(2026, 3, 1, 7, 30, 0, 0, shanghai), 24 * time.Hour, shanghai, time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai)},
+		{"local day before utc midnight", time.Date(2026, 3, 1, 23, 59, 0, 0, shanghai), 24 * time.Hour, shanghai, time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai)},
+		{"hour unaffected", time.Date(2026, 3, 1, 7, 30, 0, 0, shanghai), time.Hour, shanghai, time.Date(2026, 3, 1, 7, 0, 0, 0, shanghai)},
+		{"before epoch", time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC), 24 * time.Hour, nil, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := TrendQuery{Interval: tc.interval, Location: tc.loc}
+			if got := trendBucket(tc.at, tc.interval, q.bucketOffset()); !got.Equal(tc.want) {
+				t.Fatalf("bucket = %v, want %v", got.In(time.UTC), tc.want.In(time.UTC))
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTitleTrendLocalDays(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	store := NewMemoryStore(10)
+	// 上海同一天的两次爬取跨越 UTC 零点，应落在同一个桶
+	store.PutHotItems("weibo",
+		HotItem{Title: "热点", Rank: 1, CrawledAt: time.Date(2026, 3, 1, 7, 0, 0, 0, shanghai)},
+		HotItem{Title: "热点", Rank: 2, CrawledAt: time.Date(2026, 3, 1, 9, 0, 0, 0, shanghai)},
+		HotItem{Title: "热点", Rank: 3, CrawledAt: time.Date(2026, 3, 2, 7, 0, 0, 0, shanghai)},
+	)
+	series, err := store.GetTitleTrend(context.Background(), TrendQuery{Title: "热点", Interval: 24 * time.Hour, Location: shanghai})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 || len(series[0].Points) != 2 {
+		t.Fatalf("series = %+v, want one source with two daily points", series)
+	}
+	if start := series[0].Points[0].Time; !start.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, shanghai)) {
+		t.Fatalf("first bucket starts at %v, want local midnight", start)
+	}
+}
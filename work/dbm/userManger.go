@@ -0,0 +1,151 @@
+package dbm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	usersCollName    = "users"    // 用户集合
+	countersCollName = "counters" // 自增序列集合
+	userIDCounterKey = "user_id"  // 用户 ID 序列名
+	userIDStart      = 1000       // 用户 ID 起始值（首个用户为 1001）
+)
+
+var (
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// User 用户文档（存储于 mongodb_name_users 库的 users 集合）
+type User struct {
+	UserID       uint64    `bson:"user_id" json:"userId"`
+	Username     string    `bson:"username" json:"userName"`
+	PasswordHash string    `bson:"password_hash" json:"-"` // 密码哈希（bcrypt），不对外输出
+	Nickname     string    `bson:"nickname" json:"nickname"`
+	Roles        []string  `bson:"roles" json:"roles"`             // 角色列表
+	Permissions  []string  `bson:"permissions" json:"permissions"` // 额外授予的权限（角色权限之外）
+	CreatedAt    time.Time `bson:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+func (m *MongoManger) usersColl() *mongo.Collection {
+	return m.mongoClient.Database(m.mongodbUsersName).Collection(usersCollName)
+}
+
+// EnsureUserIndexes 创建用户集合索引（username、user_id 唯一）
+func (m *MongoManger) EnsureUserIndexes(ctx context.Context) error {
+	_, err := m.usersColl().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		return fmt.Errorf("create user indexes failed: %w", err)
+	}
+	return nil
+}
+
+// nextUserID 通过 counters 集合原子自增生成用户 ID
+func (m *MongoManger) nextUserID(ctx context.Context) (uint64, error) {
+	coll := m.mongoClient.Database(m.mongodbUsersName).Collection(countersCollName)
+	// 首次使用时初始化序列起始值（已存在则忽略）
+	_, err := coll.UpdateOne(ctx,
+		bson.M{"_id": userIDCounterKey},
+		bson.M{"$setOnInsert": bson.M{"seq": int64(userIDStart)}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("init user id counter failed: %w", err)
+	}
+
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err = coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": userIDCounterKey},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("increase user id counter failed: %w", err)
+	}
+	return uint64(counter.Seq), nil
+}
+
+// CreateUser 创建用户（passwordHash、roles 由调用方生成）
+func (m *MongoManger) CreateUser(ctx context.Context, username, passwordHash, nickname string, roles []string) (*User, error) {
+	userID, err := m.nextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if nickname == "" {
+		nickname = username
+	}
+	now := time.Now()
+	user := &User{
+		UserID:       userID,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Nickname:     nickname,
+		Roles:        roles,
+		Permissions:  []string{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if _, err := m.usersColl().InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("insert user failed: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByUsername 按用户名查询用户
+func (m *MongoManger) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return m.findOneUser(ctx, bson.M{"username": username})
+}
+
+// GetUserByID 按用户 ID 查询用户
+func (m *MongoManger) GetUserByID(ctx context.Context, userID uint64) (*User, error) {
+	return m.findOneUser(ctx, bson.M{"user_id": userID})
+}
+
+// UpdateUserRoles 更新用户的角色和额外权限
+func (m *MongoManger) UpdateUserRoles(ctx context.Context, userID uint64, roles, permissions []string) (*User, error) {
+	var user User
+	err := m.usersColl().FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{
+			"roles":       roles,
+			"permissions": permissions,
+			"updated_at":  time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("update user roles failed: %w", err)
+	}
+	return &user, nil
+}
+
+func (m *MongoManger) findOneUser(ctx context.Context, filter bson.M) (*User, error) {
+	var user User
+	err := m.usersColl().FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("find user failed: %w", err)
+	}
+	return &user, nil
+}
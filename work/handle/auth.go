@@ -1,23 +1,67 @@
 package handle
 
 import (
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 
+	"github/AHKLIC/Web/work/dbm"
 	"github/AHKLIC/Web/work/until"
 
 	"github.com/gin-gonic/gin"
 )
 
 // 获取用户信息（需认证）
-func UserProfileHandler(c *gin.Context) {
+func (h *Handler) UserProfileHandler(c *gin.Context) {
 	// 从上下文获取 JWT 解析后的用户信息
-	userID, _ := c.Get("userId")
-	username, _ := c.Get("userName")
+	userID := c.GetUint64("userId")
 
+	user, err := h.deps.Users.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, dbm.ErrUserNotFound) {
+			c.Error(&until.BusinessError{Code: 404, Message: "用户不存在"})
+			return
+		}
+		c.Error(&until.BusinessError{Code: 500, Message: "获取用户信息失败：" + err.Error()})
+		return
+	}
+
+	user.Roles = until.EffectiveRoles(user.Roles)
 	c.JSON(http.StatusOK, until.Response{
 		Code:    0,
 		Message: "获取成功",
-		Data:    gin.H{"userId": userID, "userName": username, "role": "admin"},
+		Data: gin.H{
+			"user":        user,
+			"permissions": until.EffectivePermissions(user.Roles, user.Permissions),
+		},
+	})
+}
+
+// 登出接口（需认证）：吊销当前访问令牌，并删除请求中携带的刷新令牌
+func (h *Handler) LogoutHandler(c *gin.Context) {
+	type LogoutRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	var req LogoutRequest
+	// 请求体可为空（仅吊销访问令牌）
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+			return
+		}
+	}
+
+	jti := c.GetString("jti")
+	expireAt := c.GetTime("tokenExpireAt")
+	if err := until.RevokeTokens(c.Request.Context(), h.deps.Tokens, jti, expireAt, req.RefreshToken); err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "登出失败：" + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "登出成功",
 	})
 }
 
@@ -34,9 +78,13 @@ func OperateHandler(c *gin.Context) {
 		return
 	}
 
-	// 模拟业务错误（如权限不足）
-	if req.Action == "delete" && req.Data == "admin" {
-		c.Error(&until.BusinessError{Code: 403, Message: "禁止删除管理员数据"})
+	// 删除操作需要删除权限，操作管理员数据需要管理员数据权限（路由已校验 data:operate）
+	if req.Action == "delete" && !until.HasPermission(c, until.PermDataDelete) {
+		c.Error(&until.BusinessError{Code: 403, Message: "权限不足：禁止删除数据"})
+		return
+	}
+	if req.Data == "admin" && !until.HasPermission(c, until.PermAdminData) {
+		c.Error(&until.BusinessError{Code: 403, Message: "禁止操作管理员数据"})
 		return
 	}
 
@@ -46,3 +94,71 @@ func OperateHandler(c *gin.Context) {
 		Data:    gin.H{"action": req.Action, "result": "success"},
 	})
 }
+
+// 更新用户角色（需 user:manage 权限）
+// PUT /api/auth/admin/users/:id/roles
+func (h *Handler) UpdateUserRolesHandler(c *gin.Context) {
+	type UpdateRolesRequest struct {
+		Roles       []string `json:"roles" binding:"required,min=1"`
+		Permissions []string `json:"permissions"`
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：用户 ID 无效"})
+		return
+	}
+	var req UpdateRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	for _, role := range req.Roles {
+		if !until.IsKnownRole(role) {
+			c.Error(&until.BusinessError{Code: 400, Message: "参数错误：未知角色 " + role})
+			return
+		}
+	}
+	if req.Permissions == nil {
+		req.Permissions = []string{}
+	}
+	for _, perm := range req.Permissions {
+		if !until.IsKnownPermission(perm) {
+			c.Error(&until.BusinessError{Code: 400, Message: "参数错误：未知权限 " + perm})
+			return
+		}
+	}
+
+	// admin_users 中的用户每次启动都会重新授予 admin，撤销前需先从配置中移除，避免撤销被静默覆盖
+	if !slices.Contains(req.Roles, until.RoleAdmin) {
+		target, err := h.deps.Users.GetUserByID(c.Request.Context(), userID)
+		if err != nil {
+			if errors.Is(err, dbm.ErrUserNotFound) {
+				c.Error(&until.BusinessError{Code: 404, Message: "用户不存在"})
+				return
+			}
+			c.Error(&until.BusinessError{Code: 500, Message: "更新角色失败：" + err.Error()})
+			return
+		}
+		if until.IsBootstrapAdmin(target.Username) {
+			c.Error(&until.BusinessError{Code: 409, Message: "该用户在 admin_users 配置中，请先从配置中移除再撤销管理员角色"})
+			return
+		}
+	}
+
+	user, err := h.deps.Users.UpdateUserRoles(c.Request.Context(), userID, req.Roles, req.Permissions)
+	if err != nil {
+		if errors.Is(err, dbm.ErrUserNotFound) {
+			c.Error(&until.BusinessError{Code: 404, Message: "用户不存在"})
+			return
+		}
+		c.Error(&until.BusinessError{Code: 500, Message: "更新角色失败：" + err.Error()})
+		return
+	}
+
+	// 新角色在用户下次登录或刷新令牌时生效
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "更新成功",
+		Data:    user,
+	})
+}
@@ -0,0 +1,164 @@
+package handle
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github/AHKLIC/Web/work/dbm"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultFuzzyPageSize = 20
+	maxFuzzyPageSize     = 100
+)
+
+// parseFuzzyQuery 解析并规范化检索条件
+// keyword 必填；source 可重复或逗号分隔；from/to 为 RFC3339 或 Unix 秒；sort 为 time/relevance/hot
+func parseFuzzyQuery(c *gin.Context) (dbm.FuzzyQuery, error) {
+	query := dbm.FuzzyQuery{Keyword: c.Query("keyword"), Sort: c.Query("sort")}
+	if strings.TrimSpace(query.Keyword) == "" {
+		return query, errors.New("keyword不能为空")
+	}
+	for _, source := range c.QueryArray("source") {
+		query.Sources = append(query.Sources, strings.Split(source, ",")...)
+	}
+	var err error
+	if query.From, err = parseTimeParam(c.Query("from")); err != nil {
+		return query, fmt.Errorf("from %w", err)
+	}
+	if query.To, err = parseTimeParam(c.Query("to")); err != nil {
+		return query, fmt.Errorf("to %w", err)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.From.After(query.To) {
+		return query, errors.New("from 不能晚于 to")
+	}
+	switch query.Sort {
+	case "", dbm.FuzzySortTime, dbm.FuzzySortRelevance, dbm.FuzzySortHot:
+	default:
+		return query, fmt.Errorf("sort 只能为 %s/%s/%s", dbm.FuzzySortTime, dbm.FuzzySortRelevance, dbm.FuzzySortHot)
+	}
+	return query.Normalize(), nil
+}
+
+// parseTimeParam 解析时间参数（RFC3339 或 Unix 秒），空字符串返回零值
+func parseTimeParam(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("格式无效（RFC3339 或 Unix 秒）")
+	}
+	return t, nil
+}
+
+// pageRequest 分页位置（在缓存的完整结果上截取）
+type pageRequest struct {
+	Offset int
+	Size   int
+}
+
+// parsePageRequest 解析 page/page_size 或 cursor（cursor 优先），未传的参数沿用 fallback
+// cursor 绑定缓存键，不能用于其他检索条件
+func parsePageRequest(c *gin.Context, cacheKey string, fallback pageRequest) (pageRequest, error) {
+	req := fallback
+	if req.Size <= 0 {
+		req.Size = defaultFuzzyPageSize
+	}
+	if raw := c.Query("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > maxFuzzyPageSize {
+			return req, fmt.Errorf("page_size 需在 1-%d 之间", maxFuzzyPageSize)
+		}
+		req.Size = size
+	}
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return req, errors.New("page 需大于 0")
+		}
+		req.Offset = (page - 1) * req.Size
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw, cacheKey)
+		if err != nil {
+			return req, err
+		}
+		req = cursor
+	}
+	return req, nil
+}
+
+// cursorScope 游标绑定的检索条件标识（缓存键的哈希部分）
+func cursorScope(cacheKey string) string {
+	return cacheKey[max(0, len(cacheKey)-8):]
+}
+
+func encodeCursor(cacheKey string, req pageRequest) string {
+	raw := fmt.Sprintf("%s:%d:%d", cursorScope(cacheKey), req.Offset, req.Size)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor, cacheKey string) (pageRequest, error) {
+	errInvalid := errors.New("cursor 无效或与检索条件不匹配")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageRequest{}, errInvalid
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != cursorScope(cacheKey) {
+		return pageRequest{}, errInvalid
+	}
+	offset, errOffset := strconv.Atoi(parts[1])
+	size, errSize := strconv.Atoi(parts[2])
+	if errOffset != nil || errSize != nil || offset < 0 || size < 1 || size > maxFuzzyPageSize {
+		return pageRequest{}, errInvalid
+	}
+	return pageRequest{Offset: offset, Size: size}, nil
+}
+
+// fuzzyPage 模糊检索分页结果
+type fuzzyPage struct {
+	Items      []json.RawMessage `json:"items"`
+	Total      int               `json:"total"` // 结果总数（最多 dbm.MaxFuzzyResults）
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	HasMore    bool              `json:"has_more"`
+	NextCursor string            `json:"next_cursor,omitempty"` // 下一页游标（无限滚动）
+}
+
+// buildFuzzyPage 从缓存的完整结果（JSON 数组）中截取一页
+func buildFuzzyPage(data, cacheKey string, req pageRequest) (fuzzyPage, error) {
+	var all []json.RawMessage
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &all); err != nil {
+			return fuzzyPage{}, fmt.Errorf("解析缓存结果失败: %w", err)
+		}
+	}
+	start := min(req.Offset, len(all))
+	end := min(start+req.Size, len(all))
+	page := fuzzyPage{
+		Items:    all[start:end],
+		Total:    len(all),
+		Page:     req.Offset/req.Size + 1,
+		PageSize: req.Size,
+		HasMore:  end < len(all),
+	}
+	if page.Items == nil {
+		page.Items = []json.RawMessage{}
+	}
+	if page.HasMore {
+		page.NextCursor = encodeCursor(cacheKey, pageRequest{Offset: end, Size: req.Size})
+	}
+	return page, nil
+}
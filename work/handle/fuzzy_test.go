@@ -0,0 +1,110 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageContext 构造带查询参数的请求上下文
+func pageContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+rawQuery, nil)
+	return c
+}
+
+func TestParsePageRequest(t *testing.T) {
+	cacheKey := until.GetFuzzyCacheKey(dbm.FuzzyQuery{Keyword: "go"}.Normalize())
+	otherKey := until.GetFuzzyCacheKey(dbm.FuzzyQuery{Keyword: "rust"}.Normalize())
+	cursor := encodeCursor(cacheKey, pageRequest{Offset: 40, Size: 20})
+
+	for _, tc := range []struct {
+		name     string
+		query    string
+		fallback pageRequest
+		want     pageRequest
+		wantErr  bool
+	}{
+		{"defaults", "", pageRequest{}, pageRequest{Offset: 0, Size: defaultFuzzyPageSize}, false},
+		{"submit-time fallback", "", pageRequest{Offset: 10, Size: 10}, pageRequest{Offset: 10, Size: 10}, false},
+		{"page and size", "page=3&page_size=5", pageRequest{}, pageRequest{Offset: 10, Size: 5}, false},
+		{"page uses fallback size", "page=2", pageRequest{Size: 30}, pageRequest{Offset: 30, Size: 30}, false},
+		{"cursor round trip", "cursor=" + cursor, pageRequest{}, pageRequest{Offset: 40, Size: 20}, false},
+		{"cursor wins over page", "page=1&page_size=5&cursor=" + cursor, pageRequest{}, pageRequest{Offset: 40, Size: 20}, false},
+		{"cursor from other query", "cursor=" + encodeCursor(otherKey, pageRequest{Offset: 20, Size: 20}), pageRequest{}, pageRequest{}, true},
+		{"garbage cursor", "cursor=not-a-cursor", pageRequest{}, pageRequest{}, true},
+		{"page size too large", fmt.Sprintf("page_size=%d", maxFuzzyPageSize+1), pageRequest{}, pageRequest{}, true},
+		{"page zero", "page=0", pageRequest{}, pageRequest{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parsePageRequest(pageContext(tc.query), cacheKey, tc.fallback)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildFuzzyPageCursor(t *testing.T) {
+	cacheKey := until.GetFuzzyCacheKey(dbm.FuzzyQuery{Keyword: "go"}.Normalize())
+	data, _ := json.Marshal([]int{1, 2, 3, 4, 5})
+
+	// 按 next_cursor 逐页读取，恰好覆盖全部结果
+	var seen []json.RawMessage
+	req := pageRequest{Size: 2}
+	for i := 0; ; i++ {
+		page, err := buildFuzzyPage(string(data), cacheKey, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 || page.Page != i+1 {
+			t.Fatalf("page %d: %+v", i+1, page)
+		}
+		seen = append(seen, page.Items...)
+		if !page.HasMore {
+			if page.NextCursor != "" {
+				t.Fatal("last page should not have a cursor")
+			}
+			break
+		}
+		if req, err = decodeCursor(page.NextCursor, cacheKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := json.Marshal(seen); string(got) != string(data) {
+		t.Fatalf("paged items %s, want %s", got, data)
+	}
+}
+
+func TestFuzzyResultUnknownStatus(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	r := newTestRouter(store)
+	ctx := context.Background()
+	cacheKey := until.GetFuzzyCacheKey(dbm.FuzzyQuery{Keyword: "go"}.Normalize())
+	_ = store.SetHash(ctx, until.ResultCachePrefix+"req-1", map[string]string{"cache_key": cacheKey}, time.Minute)
+	// 只写入了进度、尚无状态的缓存按处理中返回，而不是空响应
+	_ = store.SetHash(ctx, cacheKey, map[string]string{"progress": "50"}, time.Minute)
+
+	var data struct {
+		Progress int `json:"progress"`
+	}
+	resp := doJSON(t, r, http.MethodGet, "/api/public/query/fuzzy/result?req_id=req-1", "", nil, nil)
+	if resp.Code != 1 {
+		t.Fatalf("code = %d, want 1 (loading)", resp.Code)
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil || data.Progress != 50 {
+		t.Fatalf("data = %s, want progress 50", resp.Data)
+	}
+}
@@ -0,0 +1,48 @@
+package handle
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+)
+
+// Deps 处理器依赖（由 main 注入 Redis/MongoDB/RabbitMQ 实现，测试时注入 dbm.MemoryStore）
+type Deps struct {
+	HotData   dbm.HotDataStore
+	Fuzzy     dbm.FuzzySearchStore
+	Trend     dbm.TrendStore
+	Cache     dbm.CacheStore
+	Locker    dbm.Locker
+	Users     dbm.UserStore
+	Subs      dbm.SubscriptionStore
+	Tokens    dbm.TokenStore
+	Events    dbm.EventBus
+	Publisher until.Publisher
+	Mailer    until.Mailer // 发送订阅邮箱验证码（未配置 email_list 时为 nil，不支持邮件提醒）
+}
+
+// Handler 持有依赖的路由处理器集合
+type Handler struct {
+	deps  Deps
+	ready atomic.Bool // 就绪状态（启动完成后置为 true，收到退出信号后置为 false）
+
+	streamCtx   context.Context // 长连接（SSE/WebSocket）的生命周期，CloseStreams 后结束
+	stopStreams context.CancelFunc
+}
+
+func NewHandler(deps Deps) *Handler {
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	return &Handler{deps: deps, streamCtx: streamCtx, stopStreams: stopStreams}
+}
+
+// SetReady 设置就绪状态，未就绪时 /ready 返回 503，负载均衡据此摘除流量
+func (h *Handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// CloseStreams 结束所有 SSE/WebSocket 长连接（优雅退出时在关闭 HTTP 服务前调用，否则 Shutdown 会等待长连接超时）
+func (h *Handler) CloseStreams() {
+	h.stopStreams()
+}
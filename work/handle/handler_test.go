@@ -0,0 +1,333 @@
+package handle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testConfig = `{
+  "mongo_url": "mongodb://localhost:27017",
+  "mongodb_name_data": "crawlersData",
+  "mongodb_name_users": "userData",
+  "redis_mode": "standalone",
+  "redis_addrs": ["localhost:6379"],
+  "mq_backend": "memory",
+  "source_list": ["bilibili", "weibo", "zhihu"],
+  "log_level": "error",
+  "admin_users": ["root01"],
+  "jwt": {"allow_ephemeral": true}
+}`
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "handle-test")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		panic(err)
+	}
+	if err := config.Init(path); err != nil {
+		panic(err)
+	}
+	cfg := config.GetGlobalConfig()
+	if err := until.InitJWTKeys(cfg.JWT); err != nil {
+		panic(err)
+	}
+	// 访问日志投递到内存队列
+	broker, err := until.NewBroker(cfg, nil)
+	if err != nil {
+		panic(err)
+	}
+	if err := until.InitMQ(context.Background(), broker); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	until.CloseMQ()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// syncPublisher 发布模糊查询消息时直接在当前 goroutine 执行查询（代替 MQ 消费者）
+type syncPublisher struct {
+	deps until.ConsumerDeps
+}
+
+func (p syncPublisher) Publish(ctx context.Context, queueName string, body []byte, priority uint8) error {
+	var query dbm.FuzzyQuery
+	if err := json.Unmarshal(body, &query); err != nil {
+		return err
+	}
+	return until.ProcessFuzzyQuery(ctx, p.deps, query)
+}
+
+// newTestRouter 使用 MemoryStore 注入全部依赖，路由与 main 中的注册方式一致
+func newTestRouter(store *dbm.MemoryStore) *gin.Engine {
+	h := NewHandler(Deps{
+		HotData:   store,
+		Fuzzy:     store,
+		Trend:     store,
+		Cache:     store,
+		Locker:    store,
+		Users:     store,
+		Subs:      store,
+		Tokens:    store,
+		Events:    store,
+		Publisher: syncPublisher{deps: until.ConsumerDeps{Fuzzy: store, Cache: store, Events: store}},
+	})
+	r := gin.New()
+	r.Use(until.ErrorAndLogHandler())
+	public := r.Group("/api/public")
+	public.Use(until.PublicJWTMiddleware(store))
+	public.GET("/data/latest", h.GetLatestCrawleData)
+	public.GET("/query/fuzzy/search", h.SubmitFuzzyQuery)
+	public.GET("/query/fuzzy/result", h.GetFuzzyQueryResult)
+	public.POST("/register", h.RegisterHandler)
+	public.POST("/login", h.LoginHandler)
+	public.POST("/refresh", h.RefreshTokenHandler)
+	auth := r.Group("/api/auth")
+	auth.Use(until.JWTMiddleware(store))
+	auth.GET("/profile", until.RequirePermission(until.PermProfileRead), h.UserProfileHandler)
+	auth.POST("/logout", h.LogoutHandler)
+	admin := auth.Group("/admin", until.RequireRole(until.RoleAdmin))
+	admin.PUT("/users/:id/roles", until.RequirePermission(until.PermUserManage), h.UpdateUserRolesHandler)
+	return r
+}
+
+type testResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// doJSON 发送请求并解析统一响应结构，data 非 nil 时解码 Data 字段
+func doJSON(t *testing.T, r *gin.Engine, method, target, token string, body any, data any) testResponse {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: invalid response %q: %v", method, target, w.Body.String(), err)
+	}
+	if data != nil && resp.Code == 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			t.Fatalf("%s %s: decode data %s: %v", method, target, resp.Data, err)
+		}
+	}
+	return resp
+}
+
+func TestRegisterLoginRefresh(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	r := newTestRouter(store)
+	account := map[string]string{"username": "alice01", "password": "secret123"}
+
+	var user dbm.User
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/register", "", account, &user); resp.Code != 0 {
+		t.Fatalf("register: %+v", resp)
+	}
+	if user.Username != "alice01" || user.UserID == 0 {
+		t.Fatalf("register returned %+v", user)
+	}
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/register", "", account, nil); resp.Code != 409 {
+		t.Fatalf("duplicate register code = %d, want 409", resp.Code)
+	}
+	wrong := map[string]string{"username": "alice01", "password": "wrong-pass"}
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/login", "", wrong, nil); resp.Code != 403 {
+		t.Fatalf("login with wrong password code = %d, want 403", resp.Code)
+	}
+
+	var tokens until.TokenPair
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/login", "", account, &tokens); resp.Code != 0 {
+		t.Fatalf("login: %+v", resp)
+	}
+	var profile struct {
+		User        dbm.User `json:"user"`
+		Permissions []string `json:"permissions"`
+	}
+	if resp := doJSON(t, r, http.MethodGet, "/api/auth/profile", tokens.AccessToken, nil, &profile); resp.Code != 0 {
+		t.Fatalf("profile: %+v", resp)
+	}
+	if profile.User.UserID != user.UserID {
+		t.Fatalf("profile user id = %d, want %d", profile.User.UserID, user.UserID)
+	}
+
+	// 刷新令牌一次性使用：换取新令牌对后旧刷新令牌失效
+	var refreshed until.TokenPair
+	refresh := map[string]string{"refresh_token": tokens.RefreshToken}
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/refresh", "", refresh, &refreshed); resp.Code != 0 {
+		t.Fatalf("refresh: %+v", resp)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/refresh", "", refresh, nil); resp.Code != 401 {
+		t.Fatalf("reused refresh token code = %d, want 401", resp.Code)
+	}
+
+	// 登出后访问令牌被吊销
+	if resp := doJSON(t, r, http.MethodPost, "/api/auth/logout", refreshed.AccessToken, nil, nil); resp.Code != 0 {
+		t.Fatalf("logout: %+v", resp)
+	}
+	if resp := doJSON(t, r, http.MethodGet, "/api/auth/profile", refreshed.AccessToken, nil, nil); resp.Code != 401 {
+		t.Fatalf("profile after logout code = %d, want 401", resp.Code)
+	}
+}
+
+func TestFuzzySubmitThenResult(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	now := time.Now()
+	store.PutHotItems("weibo",
+		dbm.HotItem{Title: "Go 1.24 发布", URL: "https://example.com/1", HotValue: 300, CrawledAt: now.Add(-time.Hour)},
+		dbm.HotItem{Title: "Go 泛型实践", URL: "https://example.com/2", HotValue: 900, CrawledAt: now},
+	)
+	store.PutHotItems("zhihu", dbm.HotItem{Title: "Rust 与 Go 对比", URL: "https://example.com/3", HotValue: 500, CrawledAt: now.Add(-2 * time.Hour)})
+	store.PutHotItems("bilibili", dbm.HotItem{Title: "今日番剧", URL: "https://example.com/4", HotValue: 100, CrawledAt: now})
+	r := newTestRouter(store)
+
+	var pending struct {
+		ReqID string `json:"req_id"`
+	}
+	resp := doJSON(t, r, http.MethodGet, "/api/public/query/fuzzy/search?keyword=go&sort=hot&page_size=2", "", nil, nil)
+	if resp.Code != 1 {
+		t.Fatalf("submit code = %d, want 1 (pending): %+v", resp.Code, resp)
+	}
+	if err := json.Unmarshal(resp.Data, &pending); err != nil || pending.ReqID == "" {
+		t.Fatalf("submit data %s: %v", resp.Data, err)
+	}
+
+	var page struct {
+		Items    []dbm.HotItem `json:"items"`
+		Total    int           `json:"total"`
+		HasMore  bool          `json:"has_more"`
+		PageSize int           `json:"page_size"`
+	}
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/query/fuzzy/result?req_id="+pending.ReqID, "", nil, &page); resp.Code != 0 {
+		t.Fatalf("result: %+v", resp)
+	}
+	if page.Total != 3 || page.PageSize != 2 || len(page.Items) != 2 || !page.HasMore {
+		t.Fatalf("page = %+v, want 2 of 3 items", page)
+	}
+	if page.Items[0].Title != "Go 泛型实践" || page.Items[1].Title != "Rust 与 Go 对比" {
+		t.Fatalf("items not sorted by hot value: %+v", page.Items)
+	}
+
+	// 相同条件再次提交直接命中缓存
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/query/fuzzy/search?keyword=go&sort=hot&page=2&page_size=2", "", nil, &page); resp.Code != 0 {
+		t.Fatalf("cached submit: %+v", resp)
+	}
+	if len(page.Items) != 1 || page.Items[0].Title != "Go 1.24 发布" || page.HasMore {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/query/fuzzy/result?req_id=unknown", "", nil, nil); resp.Code != 404 {
+		t.Fatalf("unknown req_id code = %d, want 404", resp.Code)
+	}
+}
+
+func TestLatestData(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	crawledAt := time.Now().Truncate(time.Second)
+	store.PutBatch(dbm.HotBatch{Source: "weibo", CrawledAt: crawledAt.Add(-time.Minute), Items: []dbm.HotItem{{Title: "旧话题"}}})
+	store.PutBatch(dbm.HotBatch{Source: "weibo", CrawledAt: crawledAt, Items: []dbm.HotItem{{Title: "话题一"}, {Title: "话题二"}}})
+	store.PutBatch(dbm.HotBatch{Source: "zhihu", CrawledAt: crawledAt, Items: []dbm.HotItem{{Title: "问题一"}}})
+	r := newTestRouter(store)
+
+	var batch dbm.HotBatch
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/data/latest?source=weibo", "", nil, &batch); resp.Code != 0 {
+		t.Fatalf("latest weibo: %+v", resp)
+	}
+	if !batch.CrawledAt.Equal(crawledAt) || len(batch.Items) != 2 || batch.Items[1].Rank != 2 || batch.Items[0].Source != "weibo" {
+		t.Fatalf("latest weibo batch = %+v", batch)
+	}
+
+	var all latestBatches
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/data/latest", "", nil, &all); resp.Code != 0 {
+		t.Fatalf("latest all: %+v", resp)
+	}
+	if len(all.Batches) != 2 || all.Batches[0].Source != "weibo" || all.Batches[1].Source != "zhihu" {
+		t.Fatalf("latest batches = %+v", all.Batches)
+	}
+	if len(all.Missing) != 1 || all.Missing[0] != "bilibili" {
+		t.Fatalf("missing = %v, want [bilibili]", all.Missing)
+	}
+
+	if resp := doJSON(t, r, http.MethodGet, "/api/public/data/latest?source=douyin", "", nil, nil); resp.Code != 500 {
+		t.Fatalf("unknown source code = %d, want 500", resp.Code)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	r := newTestRouter(store)
+	account := map[string]string{"username": "root01", "password": "secret123"}
+	other := map[string]string{"username": "bob01", "password": "secret123"}
+
+	// 公开注册 admin_users 中的用户名不会直接获得 admin
+	var user dbm.User
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/register", "", account, &user); resp.Code != 0 {
+		t.Fatalf("register: %+v", resp)
+	}
+	if slices.Contains(user.Roles, until.RoleAdmin) {
+		t.Fatalf("registered user got roles %v", user.Roles)
+	}
+	var bob dbm.User
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/register", "", other, &bob); resp.Code != 0 {
+		t.Fatalf("register: %+v", resp)
+	}
+
+	// 启动时只为已注册的账号授予 admin
+	if err := until.BootstrapAdmins(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+	var tokens until.TokenPair
+	if resp := doJSON(t, r, http.MethodPost, "/api/public/login", "", account, &tokens); resp.Code != 0 {
+		t.Fatalf("login: %+v", resp)
+	}
+	promote := map[string]any{"roles": []string{until.RoleUser, until.RoleAdmin}}
+	if resp := doJSON(t, r, http.MethodPut, fmt.Sprintf("/api/auth/admin/users/%d/roles", bob.UserID), tokens.AccessToken, promote, nil); resp.Code != 0 {
+		t.Fatalf("bootstrap admin update roles: %+v", resp)
+	}
+
+	// admin_users 中的用户不能通过角色接口撤销 admin（否则下次启动会被静默恢复）
+	demote := map[string]any{"roles": []string{until.RoleUser}}
+	if resp := doJSON(t, r, http.MethodPut, fmt.Sprintf("/api/auth/admin/users/%d/roles", user.UserID), tokens.AccessToken, demote, nil); resp.Code != 409 {
+		t.Fatalf("demote bootstrap admin code = %d, want 409", resp.Code)
+	}
+	if resp := doJSON(t, r, http.MethodPut, fmt.Sprintf("/api/auth/admin/users/%d/roles", bob.UserID), tokens.AccessToken, demote, nil); resp.Code != 0 {
+		t.Fatalf("demote regular admin: %+v", resp)
+	}
+}
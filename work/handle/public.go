@@ -2,11 +2,15 @@ package handle
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
 	"net/http"
+	"strconv"
 
 	"encoding/json"
+	"github/AHKLIC/Web/work/config"
 	"github/AHKLIC/Web/work/dbm"
 	"github/AHKLIC/Web/work/until"
 	"time"
@@ -14,8 +18,44 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 注册接口（公开）
+func (h *Handler) RegisterHandler(c *gin.Context) {
+	type RegisterRequest struct {
+		Username string `json:"username" binding:"required,min=3,max=32,alphanum"`
+		Password string `json:"password" binding:"required,min=6,max=72"`
+		Nickname string `json:"nickname" binding:"max=32"`
+	}
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+
+	passwordHash, err := until.HashPassword(req.Password)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "密码加密失败"})
+		return
+	}
+
+	user, err := h.deps.Users.CreateUser(c.Request.Context(), req.Username, passwordHash, req.Nickname, until.DefaultRoles())
+	if err != nil {
+		if errors.Is(err, dbm.ErrUserExists) {
+			c.Error(&until.BusinessError{Code: 409, Message: "用户名已存在"})
+			return
+		}
+		c.Error(&until.BusinessError{Code: 500, Message: "注册失败：" + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "注册成功",
+		Data:    user,
+	})
+}
+
 // 登录接口（公开）
-func LoginHandler(c *gin.Context) {
+func (h *Handler) LoginHandler(c *gin.Context) {
 	// 绑定请求参数（用户名/密码）
 	type LoginRequest struct {
 		Username string `json:"username" binding:"required"`
@@ -27,14 +67,19 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// 模拟数据库验证（生产环境替换为真实数据库查询）
-	if req.Username != "admin" || req.Password != "123456" {
+	// 查询用户并校验密码（用户不存在与密码错误返回相同提示，避免枚举用户名）
+	user, err := h.deps.Users.GetUserByUsername(c.Request.Context(), req.Username)
+	if err != nil && !errors.Is(err, dbm.ErrUserNotFound) {
+		c.Error(&until.BusinessError{Code: 500, Message: "查询用户失败：" + err.Error()})
+		return
+	}
+	if user == nil || !until.CheckPassword(user.PasswordHash, req.Password) {
 		c.Error(&until.BusinessError{Code: 403, Message: "用户名或密码错误"})
 		return
 	}
 
-	// 生成 JWT Token
-	token, err := until.GenerateJWT(1001, req.Username)
+	// 签发访问令牌和刷新令牌
+	tokens, err := until.IssueTokenPair(c.Request.Context(), h.deps.Tokens, user)
 	if err != nil {
 		c.Error(&until.BusinessError{Code: 500, Message: "Token 生成失败"})
 		return
@@ -44,7 +89,35 @@ func LoginHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, until.Response{
 		Code:    0,
 		Message: "登录成功",
-		Data:    gin.H{"token": token, "expire_hour": until.JWTExpireHour},
+		Data:    tokens,
+	})
+}
+
+// 刷新令牌接口（公开）：旧刷新令牌换取新的令牌对
+func (h *Handler) RefreshTokenHandler(c *gin.Context) {
+	type RefreshRequest struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+
+	tokens, err := until.RefreshTokenPair(c.Request.Context(), h.deps.Tokens, h.deps.Users, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, dbm.ErrRefreshTokenInvalid) {
+			c.Error(&until.BusinessError{Code: 401, Message: "刷新令牌无效或已过期"})
+			return
+		}
+		c.Error(&until.BusinessError{Code: 500, Message: "刷新令牌失败：" + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "刷新成功",
+		Data:    tokens,
 	})
 }
 
@@ -57,14 +130,44 @@ func HealthCheck(c *gin.Context) {
 	})
 }
 
+// 就绪检查接口（公开）：启动完成前和优雅退出期间返回 503
+func (h *Handler) ReadinessCheck(c *gin.Context) {
+	if !h.ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, until.Response{
+			Code:    503,
+			Message: "服务未就绪",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "服务就绪",
+	})
+}
+
+// JWKS 公钥接口（公开）
+// GET /.well-known/jwks.json
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": until.JWKS()})
+}
+
+// HotSchemaHandler 热榜数据结构的 JSON Schema（公开，供客户端生成代码）
+// GET /api/public/schema/hot
+func HotSchemaHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, dbm.HotSchema())
+}
+
 // ?source=XXXX
-func GetLatestCrawleData(c *gin.Context) {
+// 不传 source 时返回 source_list 中全部数据源的最新批次（按配置顺序），无数据的数据源列在 missing 中
+func (h *Handler) GetLatestCrawleData(c *gin.Context) {
 	source := c.Query("source")
 	if source == "" {
-		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：source不能为空"})
+		h.getAllLatestData(c)
 		return
 	}
-	data, err := dbm.AllDbManger.RedisManger.GetLatestDataBySource(c.Request.Context(), source)
+	data, err := h.deps.HotData.GetLatestDataBySource(c.Request.Context(), source)
 	if err != nil {
 		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
 		return
@@ -78,39 +181,167 @@ func GetLatestCrawleData(c *gin.Context) {
 
 }
 
-// / api/public/query/fuzzy/search?keyword=XXX
-func SubmitFuzzyQuery(c *gin.Context) {
+// latestBatches 多数据源最新批次
+type latestBatches struct {
+	Batches []*dbm.HotBatch `json:"batches"`
+	Missing []string        `json:"missing"` // 暂无数据的数据源
+}
 
-	keyword := c.Query("keyword")
+func (h *Handler) getAllLatestData(c *gin.Context) {
+	sources := config.GetGlobalConfig().SourceList
+	batches, err := h.deps.HotData.GetLatestDataBySources(c.Request.Context(), sources)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	data := latestBatches{Batches: make([]*dbm.HotBatch, 0, len(sources)), Missing: []string{}}
+	for _, source := range sources {
+		if batch, ok := batches[source]; ok {
+			data.Batches = append(data.Batches, batch)
+		} else {
+			data.Missing = append(data.Missing, source)
+		}
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    data,
+	})
+}
+
+// GetCrawleDataHistory 某数据源的历史批次（按爬取时间倒序，最多 redis_max_batches 个）
+// GET /api/public/data/history?source=XXX[&from=&to=]（RFC3339 或 Unix 秒）
+func (h *Handler) GetCrawleDataHistory(c *gin.Context) {
+	source := c.Query("source")
+	if source == "" {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：source不能为空"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：from " + err.Error()})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：to " + err.Error()})
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：from 不能晚于 to"})
+		return
+	}
+	batches, err := h.deps.HotData.GetBatchHistory(c.Request.Context(), source, from, to)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    gin.H{"source": source, "batches": batches},
+	})
+}
+
+// GetBatchDiff 对比某数据源的两个批次：新上榜、下榜、名次上升/下降及热度变化
+// GET /api/public/data/diff?source=XXX[&target=][&base=]（RFC3339 或 Unix 秒）
+// target 取该时间及之前最新的批次（默认最新批次），base 同理（默认 target 的上一批次）
+func (h *Handler) GetBatchDiff(c *gin.Context) {
+	source := c.Query("source")
+	if source == "" {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：source不能为空"})
+		return
+	}
+	targetAt, err := parseTimeParam(c.Query("target"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：target " + err.Error()})
+		return
+	}
+	baseAt, err := parseTimeParam(c.Query("base"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：base " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	batches, err := h.deps.HotData.GetBatchHistory(ctx, source, time.Time{}, targetAt)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	if len(batches) == 0 {
+		c.Error(&until.BusinessError{Code: 404, Message: "未找到对比批次"})
+		return
+	}
+	target := batches[0]
+	var base *dbm.HotBatch
+	if baseAt.IsZero() {
+		if len(batches) > 1 {
+			base = batches[1]
+		}
+	} else {
+		for _, batch := range batches {
+			if !batch.CrawledAt.After(baseAt) {
+				base = batch
+				break
+			}
+		}
+	}
+	if base == nil || !base.CrawledAt.Before(target.CrawledAt) {
+		c.Error(&until.BusinessError{Code: 404, Message: "未找到早于目标批次的对比批次"})
+		return
+	}
+
+	// 批次内容不变，对比结果按两个批次缓存
+	cacheKey := until.GetBatchDiffCacheKey(source, base.CrawledAt, target.CrawledAt)
+	if cached, err := h.deps.Cache.GetHash(ctx, cacheKey); err == nil && cached["data"] != "" {
+		c.JSON(http.StatusOK, until.Response{Code: 0, Message: "获取成功", Data: json.RawMessage(cached["data"])})
+		return
+	}
+	diff := dbm.DiffBatches(base, target)
+	if data, err := json.Marshal(diff); err == nil {
+		if err := h.deps.Cache.SetHash(ctx, cacheKey, map[string]string{"data": string(data)}, until.BatchDiffCacheExpire); err != nil {
+			slog.Warn("写入批次对比缓存失败", "source", source, "error", err)
+		}
+	}
+	c.JSON(http.StatusOK, until.Response{Code: 0, Message: "获取成功", Data: diff})
+}
+
+// / api/public/query/fuzzy/search?keyword=XXX[&source=weibo,zhihu][&from=&to=][&sort=time|relevance|hot][&page=&page_size=|&cursor=]
+// 缓存键由规范化后的完整检索条件生成（不含分页），各页共享同一份缓存结果
+func (h *Handler) SubmitFuzzyQuery(c *gin.Context) {
+
+	query, err := parseFuzzyQuery(c)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	keyword := query.Keyword
 	var priority uint8
 	userType := c.GetString("user_type")
 	if userType == until.UserTypeVIP {
 		priority = 10
 	}
-	if keyword == "" {
-		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：keyword不能为空"})
-		return
-	}
 
 	// 1. 生成缓存键和锁键
-	cacheKey := until.GetFuzzyCacheKey(keyword)
-	lockKey := until.GetFuzzyLockKey(keyword)
+	cacheKey := until.GetFuzzyCacheKey(query)
+	lockKey := until.GetFuzzyLockKey(query)
 	ctx := c.Request.Context()
+	page, err := parsePageRequest(c, cacheKey, pageRequest{})
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
 
-	// 2. 查 Redis 缓存：如果已就绪，直接返回
-	data, status, err := dbm.AllDbManger.RedisManger.GetDataByKey(ctx, cacheKey)
+	// 2. 查缓存：如果已就绪，直接返回
+	cacheData, err := h.deps.Cache.GetHash(ctx, cacheKey)
 	if err != nil {
 		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
 		return
 	}
 
-	if data != nil {
-
-		c.JSON(http.StatusOK, until.Response{
-			Code:    0,
-			Message: "获取成功",
-			Data:    data,
-		})
+	if cacheData["status"] == "ready" {
+		h.respondFuzzyPage(c, cacheData, cacheKey, page)
 		return
 	}
 
@@ -120,34 +351,31 @@ func SubmitFuzzyQuery(c *gin.Context) {
 
 	// 4. 分布式锁：防止同一 keyword 被多个请求重复触发 DB 查询
 	lockVal := until.GenerateReqID()
-	writeClient := dbm.AllDbManger.RedisManger.GetMasterClient()
-	lockSuccess, err := writeClient.SetNX(
-		ctx, lockKey, lockVal, 5*time.Second, // 锁过期 5 秒（大于 DB 查询耗时）
-	).Result()
+	lockSuccess, err := h.deps.Locker.TryLock(ctx, lockKey, lockVal, 5*time.Second) // 锁过期 5 秒（大于 DB 查询耗时）
 	if err != nil {
 		c.Error(&until.BusinessError{Code: 500, Message: "获取锁失败：" + err.Error()})
 		return
 	}
 
 	// 5. 无锁且缓存未加载 → 发 MQ 异步查 DB
-	if lockSuccess && status != "loading" {
+	if lockSuccess && cacheData["status"] != "loading" {
 		// 更新缓存状态为 loading（避免其他请求重复发 MQ）
-		writeClient.HSet(
-			ctx, cacheKey,
-			"status", "loading",
-			"keyword", keyword,
-			"create_time", time.Now().Format("2006-01-02 15:04:05"),
-		)
-		writeClient.Expire(ctx, cacheKey, until.FuzzyCacheExpire)
-
-		// 发 MQ 消息（携带关键词）
-		msgBody := map[string]string{
-			"keyword": keyword,
+		if err := h.deps.Cache.SetHash(ctx, cacheKey, map[string]string{
+			"status":      "loading",
+			"keyword":     keyword,
+			"create_time": time.Now().Format("2006-01-02 15:04:05"),
+		}, until.FuzzyCacheExpire); err != nil {
+			c.Error(&until.BusinessError{Code: 500, Message: "更新缓存状态失败：" + err.Error()})
+			return
 		}
-		msgJSON, _ := json.Marshal(msgBody)
+
+		// 发 MQ 消息（携带规范化后的检索条件）
+		msgJSON, _ := json.Marshal(query)
 		publishCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
-		if err := until.PublishPriorityMQ(publishCtx, until.FuzzyQueueName, msgJSON, priority); err != nil {
+		if err := h.deps.Publisher.Publish(publishCtx, until.FuzzyQueueName, msgJSON, priority); err != nil {
+			// 释放锁，允许后续请求重新触发查询
+			_ = h.deps.Locker.Unlock(ctx, lockKey, lockVal)
 			c.Error(&until.BusinessError{Code: 500, Message: "发布模糊查询 MQ 消息失败 keyword:" + keyword + " error:" + err.Error()})
 			return
 			// MQ 失败，降级为同步查 DB
@@ -156,81 +384,185 @@ func SubmitFuzzyQuery(c *gin.Context) {
 	}
 
 	// 6. 存储请求状态（用于轮询）
-	writeClient.HSet(
-		ctx, reqStatusKey,
-		"status", "pending",
-		"keyword", keyword,
-		"cache_key", cacheKey,
-	)
-	writeClient.Expire(ctx, reqStatusKey, 5*time.Minute)
+	if err := h.deps.Cache.SetHash(ctx, reqStatusKey, map[string]string{
+		"status":    "pending",
+		"keyword":   keyword,
+		"cache_key": cacheKey,
+		"offset":    strconv.Itoa(page.Offset),
+		"page_size": strconv.Itoa(page.Size),
+	}, 5*time.Minute); err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "保存请求状态失败：" + err.Error()})
+		return
+	}
 
 	// 7. 无缓存，返回轮询提示
 	c.JSON(http.StatusOK, until.Response{
 		Code:    1,
-		Message: "数据查询中，请轮询获取结果",
+		Message: "数据查询中，请轮询或订阅获取结果",
 		Data: gin.H{
-			"req_id":   reqID,
-			"poll_url": fmt.Sprintf("/api/public/query/fuzzy/result?req_id=%s", reqID),
+			"req_id":     reqID,
+			"poll_url":   fmt.Sprintf("/api/public/query/fuzzy/result?req_id=%s", reqID),
+			"stream_url": fmt.Sprintf("/api/public/query/fuzzy/stream?req_id=%s", reqID), // SSE，或以 WebSocket 方式连接
 		},
 	})
 }
 
 // GetFuzzyQueryResult 轮询模糊查询结果
-// GET /api/public/query/fuzzy/result?req_id=xxx
-func GetFuzzyQueryResult(c *gin.Context) {
+// GET /api/public/query/fuzzy/result?req_id=xxx[&wait=10s][&view=topics]
+// 带 wait 时为长轮询：查询结束（ready/failed）或等待超时后才返回，最长 maxLongPollWait
+func (h *Handler) GetFuzzyQueryResult(c *gin.Context) {
 	reqID := c.Query("req_id")
 	if reqID == "" {
 		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：req_id不能为空"})
 		return
 	}
+	wait, err := parseLongPollWait(c.Query("wait"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
 
-	reqStatusKey := fmt.Sprintf("%s%s", until.ResultCachePrefix, reqID)
 	ctx := c.Request.Context()
 
-	readClient, err := dbm.AllDbManger.RedisManger.GetSlaveClient()
+	// 1. 查请求状态（page/page_size/cursor 可覆盖提交时的分页）
+	fuzzyReq, err := h.lookupFuzzyRequest(ctx, reqID)
 	if err != nil {
-		c.Error(&until.BusinessError{Code: 500, Message: "获取从节点客户端失败：" + err.Error()})
+		c.Error(err)
 		return
 	}
-	// 1. 查请求状态
-	statusMap, err := readClient.HGetAll(ctx, reqStatusKey).Result()
-	if err != nil || len(statusMap) == 0 {
-		c.Error(&until.BusinessError{Code: 404, Message: "请求不存在或已过期"})
+	cacheKey := fuzzyReq.CacheKey
+	page, err := parsePageRequest(c, cacheKey, fuzzyReq.Page)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
 		return
 	}
+	if wait > 0 {
+		h.waitFuzzyDone(ctx, cacheKey, wait)
+	}
 
-	cacheKey := statusMap["cache_key"]
-
-	// 2. 查缓存状态
-	cacheData, err := readClient.HGetAll(ctx, cacheKey).Result()
+	// 2. 查缓存状态（从节点尚未就绪时读主节点，避免复制延迟导致已完成的结果仍显示处理中）
+	cacheData, err := h.deps.Cache.GetHash(ctx, cacheKey)
+	if err == nil && cacheData["status"] != "ready" && cacheData["status"] != "failed" {
+		cacheData, err = h.deps.Cache.GetHashConsistent(ctx, cacheKey)
+	}
 	if err != nil || len(cacheData) == 0 {
 		c.JSON(http.StatusOK, until.Response{
 			Code:    1,
 			Message: "数据查询中，建议 1 秒后再轮询",
-			Data:    gin.H{"req_id": reqID, "progress": 50},
+			Data:    gin.H{"req_id": reqID, "progress": 0},
 		})
 		return
 	}
 
 	switch cacheData["status"] {
-	case "loading":
-		// 处理中
-		c.JSON(http.StatusOK, until.Response{
-			Code:    1,
-			Message: "数据查询中，建议 1 秒后再轮询",
-			Data:    gin.H{"req_id": reqID, "progress": 70},
-		})
 	case "failed":
 		// 处理失败
 		c.Error(&until.BusinessError{Code: 500, Message: "查询失败：" + cacheData["error_msg"]})
 	case "ready":
-		// 处理成功，返回完整结果
-		var data interface{}
-		json.Unmarshal([]byte(cacheData["data"]), &data)
+		// 处理成功，返回请求的分页
+		h.respondFuzzyPage(c, cacheData, cacheKey, page)
+	default:
+		// 处理中（loading，或只写入了进度尚无状态）
 		c.JSON(http.StatusOK, until.Response{
-			Code:    0,
-			Message: "获取成功",
-			Data:    data,
+			Code:    1,
+			Message: "数据查询中，建议 1 秒后再轮询",
+			Data:    gin.H{"req_id": reqID, "progress": cacheProgress(cacheData)},
 		})
 	}
 }
+
+// fuzzyRequest 轮询/订阅请求对应的缓存键和提交时的分页
+type fuzzyRequest struct {
+	CacheKey string
+	Page     pageRequest
+}
+
+// lookupFuzzyRequest 根据 req_id 查询对应的模糊查询请求（从节点未同步到刚写入的请求时回退主节点）
+func (h *Handler) lookupFuzzyRequest(ctx context.Context, reqID string) (fuzzyRequest, error) {
+	reqStatusKey := fmt.Sprintf("%s%s", until.ResultCachePrefix, reqID)
+	statusMap, err := h.deps.Cache.GetHash(ctx, reqStatusKey)
+	if err == nil && len(statusMap) == 0 {
+		statusMap, err = h.deps.Cache.GetHashConsistent(ctx, reqStatusKey)
+	}
+	if err != nil || len(statusMap) == 0 {
+		return fuzzyRequest{}, &until.BusinessError{Code: 404, Message: "请求不存在或已过期"}
+	}
+	offset, _ := strconv.Atoi(statusMap["offset"])
+	size, _ := strconv.Atoi(statusMap["page_size"])
+	return fuzzyRequest{
+		CacheKey: statusMap["cache_key"],
+		Page:     pageRequest{Offset: offset, Size: size},
+	}, nil
+}
+
+// respondFuzzyPage 返回已就绪缓存结果中的一页；view=topics 时改为返回完整结果的话题聚类
+func (h *Handler) respondFuzzyPage(c *gin.Context, cacheData map[string]string, cacheKey string, req pageRequest) {
+	if c.Query("view") == "topics" {
+		respondFuzzyTopics(c, cacheData["data"])
+		return
+	}
+	page, err := buildFuzzyPage(cacheData["data"], cacheKey, req)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    page,
+	})
+}
+
+// maxLongPollWait 长轮询最长等待时间（需小于网关/CDN 的请求超时）
+const maxLongPollWait = 30 * time.Second
+
+// parseLongPollWait 解析 wait 参数（如 10s、500ms，纯数字按秒计），超过上限时取上限
+func parseLongPollWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(raw)
+		if convErr != nil {
+			return 0, fmt.Errorf("wait 格式无效（如 10s）")
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("wait 不能为负数")
+	}
+	return min(wait, maxLongPollWait), nil
+}
+
+// waitFuzzyDone 长轮询：订阅完成事件后等待查询结束、超时、连接断开或服务退出
+// 订阅失败时不等待，退化为普通轮询
+func (h *Handler) waitFuzzyDone(ctx context.Context, cacheKey string, wait time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	stop := context.AfterFunc(h.streamCtx, cancel)
+	defer stop()
+
+	// 先订阅再读缓存，避免两者之间完成的事件丢失
+	events, err := h.deps.Events.SubscribeEvents(ctx, until.GetFuzzyEventChannel(cacheKey))
+	if err != nil {
+		slog.Warn("订阅模糊查询完成事件失败，退化为普通轮询", "error", err)
+		return
+	}
+	cacheData, err := h.deps.Cache.GetHashConsistent(ctx, cacheKey)
+	if err != nil || cacheData["status"] == "ready" || cacheData["status"] == "failed" {
+		return
+	}
+	for payload := range events {
+		var event until.FuzzyEvent
+		if json.Unmarshal(payload, &event) == nil && (event.Status == "ready" || event.Status == "failed") {
+			return
+		}
+	}
+}
+
+// cacheProgress 读取消费者写入的查询进度（0-100）
+func cacheProgress(cacheData map[string]string) int {
+	progress, _ := strconv.Atoi(cacheData["progress"])
+	return progress
+}
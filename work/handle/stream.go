@@ -0,0 +1,183 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	fuzzyStreamTimeout   = 2 * time.Minute  // 单个订阅连接的最长时间，超时后客户端可重连或改为轮询
+	fuzzyStreamHeartbeat = 15 * time.Second // 心跳间隔，防止代理关闭空闲连接
+)
+
+// streamSender 推送一条事件（SSE 事件名 / WebSocket 消息的 event 字段）
+type streamSender func(event string, data any) error
+
+// fuzzyStreamRequest 订阅的请求（结果按 page 分页推送）
+type fuzzyStreamRequest struct {
+	reqID    string
+	cacheKey string
+	page     pageRequest
+}
+
+// wsFrame WebSocket 消息格式
+type wsFrame struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// StreamFuzzyQueryResult 实时推送模糊查询进度与结果
+// GET /api/public/query/fuzzy/stream?req_id=xxx
+// 默认使用 SSE；以 WebSocket 方式连接（Upgrade: websocket）时推送相同内容的 JSON 消息
+// 事件：progress（进度）→ result（结果）或 error（失败），以及 ping（心跳）、timeout（超时）
+func (h *Handler) StreamFuzzyQueryResult(c *gin.Context) {
+	reqID := c.Query("req_id")
+	if reqID == "" {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：req_id不能为空"})
+		return
+	}
+	fuzzyReq, err := h.lookupFuzzyRequest(c.Request.Context(), reqID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	cacheKey := fuzzyReq.CacheKey
+	page, err := parsePageRequest(c, cacheKey, fuzzyReq.Page)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	req := fuzzyStreamRequest{reqID: reqID, cacheKey: cacheKey, page: page}
+
+	// 连接在客户端断开、超时或服务退出时结束
+	ctx, cancel := context.WithTimeout(c.Request.Context(), fuzzyStreamTimeout)
+	defer cancel()
+	stop := context.AfterFunc(h.streamCtx, cancel)
+	defer stop()
+
+	// 先订阅再读缓存，避免读缓存与订阅之间完成的事件丢失
+	events, err := h.deps.Events.SubscribeEvents(ctx, until.GetFuzzyEventChannel(cacheKey))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "订阅查询进度失败：" + err.Error()})
+		return
+	}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		h.serveFuzzyWebSocket(c, ctx, cancel, req, events)
+		return
+	}
+	h.serveFuzzySSE(c, ctx, req, events)
+}
+
+// serveFuzzySSE 以 Server-Sent Events 推送
+func (h *Handler) serveFuzzySSE(c *gin.Context, ctx context.Context, req fuzzyStreamRequest, events <-chan []byte) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+	c.Status(http.StatusOK)
+	h.watchFuzzyResult(ctx, req, events, func(event string, data any) error {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+}
+
+// serveFuzzyWebSocket 以 WebSocket 推送（客户端无需发送消息，关闭连接即取消订阅）
+func (h *Handler) serveFuzzyWebSocket(c *gin.Context, ctx context.Context, cancel context.CancelFunc, req fuzzyStreamRequest, events <-chan []byte) {
+	server := websocket.Server{
+		// 与 HTTP 接口一致不校验 Origin（公开只读数据）
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			// 读协程：客户端关闭连接时结束订阅
+			go func() {
+				defer cancel()
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+			h.watchFuzzyResult(ctx, req, events, func(event string, data any) error {
+				return websocket.JSON.Send(ws, wsFrame{Event: event, Data: data})
+			})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// watchFuzzyResult 推送当前状态，之后按事件推送进度，直到结果就绪、失败、超时或连接断开
+func (h *Handler) watchFuzzyResult(ctx context.Context, req fuzzyStreamRequest, events <-chan []byte, send streamSender) {
+	// 已完成的查询直接返回结果
+	if done, err := h.sendFuzzyState(ctx, req, send); done || err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(fuzzyStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = send("timeout", until.Response{Code: 1, Message: "订阅已结束，请重新订阅或轮询获取结果", Data: gin.H{"req_id": req.reqID}})
+			return
+		case <-heartbeat.C:
+			if err := send("ping", gin.H{"time": time.Now().Unix()}); err != nil {
+				return
+			}
+		case payload, ok := <-events:
+			if !ok {
+				return
+			}
+			var event until.FuzzyEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				slog.Warn("解析模糊查询事件失败", "error", err)
+				continue
+			}
+			if event.Status == "loading" {
+				if err := send("progress", until.Response{
+					Code:    1,
+					Message: "数据查询中",
+					Data:    gin.H{"req_id": req.reqID, "progress": event.Progress, "collection": event.Collection},
+				}); err != nil {
+					return
+				}
+				continue
+			}
+			// ready/failed：以缓存为准读取完整结果
+			if done, err := h.sendFuzzyState(ctx, req, send); done || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sendFuzzyState 从主节点读取缓存并推送当前状态，done 表示查询已结束（已推送 result 或 error）
+func (h *Handler) sendFuzzyState(ctx context.Context, req fuzzyStreamRequest, send streamSender) (done bool, err error) {
+	cacheData, err := h.deps.Cache.GetHashConsistent(ctx, req.cacheKey)
+	if err != nil {
+		return true, send("error", until.Response{Code: 500, Message: "获取数据失败：" + err.Error()})
+	}
+	switch cacheData["status"] {
+	case "ready":
+		page, err := buildFuzzyPage(cacheData["data"], req.cacheKey, req.page)
+		if err != nil {
+			return true, send("error", until.Response{Code: 500, Message: "获取数据失败：" + err.Error()})
+		}
+		return true, send("result", until.Response{Code: 0, Message: "获取成功", Data: page})
+	case "failed":
+		return true, send("error", until.Response{Code: 500, Message: "查询失败：" + cacheData["error_msg"]})
+	default:
+		return false, send("progress", until.Response{
+			Code:    1,
+			Message: "数据查询中",
+			Data:    gin.H{"req_id": req.reqID, "progress": cacheProgress(cacheData)},
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package handle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSubscriptionKeywords = 10
+	maxKeywordLength        = 50 // 单个关键词最多字符数
+)
+
+// subscriptionRequest 创建订阅的请求体（keywords 与 sources 至少填一项，email 与 webhook 至少填一项）
+type subscriptionRequest struct {
+	Keywords []string `json:"keywords"`
+	Sources  []string `json:"sources"`
+	Email    string   `json:"email"`
+	Webhook  string   `json:"webhook"`
+}
+
+// CreateSubscriptionHandler 创建关键词/数据源订阅（需认证）
+// POST /api/auth/subscriptions
+func (h *Handler) CreateSubscriptionHandler(c *gin.Context) {
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	userID := c.GetUint64("userId")
+	ctx := c.Request.Context()
+	sub, err := h.newSubscription(ctx, userID, req)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+
+	limit := config.GetGlobalConfig().Notify.MaxSubscriptions
+	count, err := h.deps.Subs.CountSubscriptions(ctx, userID)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "创建订阅失败：" + err.Error()})
+		return
+	}
+	if count >= limit {
+		c.Error(&until.BusinessError{Code: 409, Message: fmt.Sprintf("订阅数已达上限（%d）", limit)})
+		return
+	}
+	message := "订阅成功"
+	if sub.Email != "" {
+		// 邮箱需验证后才会收到提醒：发送验证码，按用户限制发送频率
+		ok, err := h.deps.Locker.TryLock(ctx, fmt.Sprintf("%s%d", until.EmailVerifyRatePrefix, userID), sub.ID, until.EmailVerifyCooldown)
+		if err != nil {
+			c.Error(&until.BusinessError{Code: 500, Message: "创建订阅失败：" + err.Error()})
+			return
+		}
+		if !ok {
+			c.Error(&until.BusinessError{Code: 429, Message: "验证邮件发送过于频繁，请稍后再试"})
+			return
+		}
+		code, err := until.NewEmailVerifyCode()
+		if err != nil {
+			c.Error(&until.BusinessError{Code: 500, Message: "创建订阅失败：" + err.Error()})
+			return
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, config.GetGlobalConfig().Notify.SendTimeout.Duration)
+		err = until.SendEmailVerifyCode(sendCtx, h.deps.Mailer, sub.Email, sub.ID, code)
+		cancel()
+		if err != nil {
+			c.Error(&until.BusinessError{Code: 502, Message: "发送验证邮件失败：" + err.Error()})
+			return
+		}
+		sub.EmailCodeHash = until.HashEmailVerifyCode(code)
+		sub.EmailCodeExp = time.Now().Add(until.EmailVerifyCodeTTL)
+		message = "订阅成功，请查收验证邮件并提交验证码以启用邮件提醒"
+	}
+	if err := h.deps.Subs.CreateSubscription(ctx, sub); err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "创建订阅失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: message,
+		Data:    sub,
+	})
+}
+
+// verifyEmailRequest 提交邮箱验证码的请求体
+type verifyEmailRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// VerifySubscriptionEmailHandler 提交订阅邮箱验证码，验证通过后该邮箱开始接收提醒（需认证）
+// POST /api/auth/subscriptions/:id/verify
+func (h *Handler) VerifySubscriptionEmailHandler(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	err := h.deps.Subs.VerifySubscriptionEmail(c.Request.Context(), c.GetUint64("userId"), c.Param("id"), until.HashEmailVerifyCode(req.Code))
+	if err != nil {
+		switch {
+		case errors.Is(err, dbm.ErrSubscriptionNotFound):
+			c.Error(&until.BusinessError{Code: 404, Message: "订阅不存在"})
+		case errors.Is(err, dbm.ErrEmailCodeInvalid):
+			c.Error(&until.BusinessError{Code: 400, Message: "验证码错误或已过期"})
+		default:
+			c.Error(&until.BusinessError{Code: 500, Message: "验证邮箱失败：" + err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "邮箱验证成功",
+	})
+}
+
+// ListSubscriptionsHandler 当前用户的订阅（需认证）
+// GET /api/auth/subscriptions
+func (h *Handler) ListSubscriptionsHandler(c *gin.Context) {
+	subs, err := h.deps.Subs.ListSubscriptions(c.Request.Context(), c.GetUint64("userId"))
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取订阅失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    subs,
+	})
+}
+
+// DeleteSubscriptionHandler 删除当前用户的订阅（需认证）
+// DELETE /api/auth/subscriptions/:id
+func (h *Handler) DeleteSubscriptionHandler(c *gin.Context) {
+	err := h.deps.Subs.DeleteSubscription(c.Request.Context(), c.GetUint64("userId"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, dbm.ErrSubscriptionNotFound) {
+			c.Error(&until.BusinessError{Code: 404, Message: "订阅不存在"})
+			return
+		}
+		c.Error(&until.BusinessError{Code: 500, Message: "删除订阅失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "删除成功",
+	})
+}
+
+// newSubscription 校验并规范化订阅：关键词去空白去重，数据源须在 source_list 中，邮箱格式合法，回调地址须为公网地址
+func (h *Handler) newSubscription(ctx context.Context, userID uint64, req subscriptionRequest) (dbm.Subscription, error) {
+	cfg := config.GetGlobalConfig()
+	sub := dbm.Subscription{
+		ID:        dbm.NewSubscriptionID(),
+		UserID:    userID,
+		Keywords:  []string{},
+		Sources:   []string{},
+		CreatedAt: time.Now(),
+	}
+	for _, keyword := range req.Keywords {
+		keyword = strings.Join(strings.Fields(keyword), " ")
+		if keyword == "" || slices.Contains(sub.Keywords, keyword) {
+			continue
+		}
+		if utf8.RuneCountInString(keyword) > maxKeywordLength {
+			return sub, fmt.Errorf("关键词最多 %d 个字符", maxKeywordLength)
+		}
+		sub.Keywords = append(sub.Keywords, keyword)
+	}
+	if len(sub.Keywords) > maxSubscriptionKeywords {
+		return sub, fmt.Errorf("关键词最多 %d 个", maxSubscriptionKeywords)
+	}
+	for _, source := range req.Sources {
+		source = strings.ToLower(strings.TrimSpace(source))
+		if source == "" || slices.Contains(sub.Sources, source) {
+			continue
+		}
+		if !slices.Contains(cfg.SourceList, source) {
+			return sub, fmt.Errorf("数据源 %s 不存在", source)
+		}
+		sub.Sources = append(sub.Sources, source)
+	}
+	if len(sub.Keywords) == 0 && len(sub.Sources) == 0 {
+		return sub, errors.New("keywords 与 sources 至少填写一项")
+	}
+
+	if req.Email = strings.TrimSpace(req.Email); req.Email != "" {
+		if h.deps.Mailer == nil {
+			return sub, errors.New("未配置发信邮箱，暂不支持邮件提醒")
+		}
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil {
+			return sub, errors.New("email 格式无效")
+		}
+		sub.Email = addr.Address
+	}
+	if req.Webhook = strings.TrimSpace(req.Webhook); req.Webhook != "" {
+		u, err := until.CheckWebhookURL(ctx, req.Webhook)
+		if err != nil {
+			return sub, err
+		}
+		sub.Webhook = u.String()
+	}
+	if sub.Email == "" && sub.Webhook == "" {
+		return sub, errors.New("email 与 webhook 至少填写一项")
+	}
+	return sub, nil
+}
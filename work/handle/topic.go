@@ -0,0 +1,122 @@
+package handle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTopicLimit = 50
+	maxTopicLimit     = 200
+)
+
+// topicOptions 话题列表的过滤条件
+type topicOptions struct {
+	MinSources int // 至少涉及的数据源数
+	Limit      int
+}
+
+// topicList 话题聚类结果
+type topicList struct {
+	Sources []string           `json:"sources"` // 参与聚类的数据源
+	Total   int                `json:"total"`   // 过滤后的话题总数
+	Topics  []dbm.TopicCluster `json:"topics"`
+}
+
+// GetLatestTopics 各数据源最新批次中跨平台的同一话题
+// GET /api/public/topics/latest[?min_sources=2][&limit=50]
+func (h *Handler) GetLatestTopics(c *gin.Context) {
+	opts, err := parseTopicOptions(c)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	sources := config.GetGlobalConfig().SourceList
+	batches, err := h.deps.HotData.GetLatestDataBySources(c.Request.Context(), sources)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	var items []dbm.HotItem
+	clustered := make([]string, 0, len(batches))
+	for _, source := range sources {
+		if batch, ok := batches[source]; ok {
+			items = append(items, batch.Items...)
+			clustered = append(clustered, source)
+		}
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    buildTopicList(clustered, items, opts),
+	})
+}
+
+// respondFuzzyTopics 对已就绪的模糊检索完整结果（不分页）聚类
+func respondFuzzyTopics(c *gin.Context, data string) {
+	opts, err := parseTopicOptions(c)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	var items []dbm.HotItem
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &items); err != nil {
+			c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：解析缓存结果失败: " + err.Error()})
+			return
+		}
+	}
+	var sources []string
+	for _, item := range items {
+		sources = append(sources, item.Source)
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data:    buildTopicList(sources, items, opts),
+	})
+}
+
+func buildTopicList(sources []string, items []dbm.HotItem, opts topicOptions) topicList {
+	sources = slices.Compact(slices.Sorted(slices.Values(sources)))
+	list := topicList{Sources: sources, Topics: []dbm.TopicCluster{}}
+	for _, topic := range dbm.ClusterTopics(items) {
+		if len(topic.Sources) >= opts.MinSources {
+			list.Topics = append(list.Topics, topic)
+		}
+	}
+	list.Total = len(list.Topics)
+	if len(list.Topics) > opts.Limit {
+		list.Topics = list.Topics[:opts.Limit]
+	}
+	return list
+}
+
+func parseTopicOptions(c *gin.Context) (topicOptions, error) {
+	opts := topicOptions{MinSources: 1, Limit: defaultTopicLimit}
+	if raw := c.Query("min_sources"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return opts, errors.New("min_sources 需大于 0")
+		}
+		opts.MinSources = n
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxTopicLimit {
+			return opts, fmt.Errorf("limit 需在 1-%d 之间", maxTopicLimit)
+		}
+		opts.Limit = n
+	}
+	return opts, nil
+}
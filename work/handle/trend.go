@@ -0,0 +1,100 @@
+package handle
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+	"github/AHKLIC/Web/work/until"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTrendInterval = time.Hour
+	minTrendInterval     = time.Minute
+	defaultTrendWindow   = 7 * 24 * time.Hour // 未指定 from 时向前查询的时长
+	maxTrendBuckets      = 1000               // 单次查询最多的时间桶数
+)
+
+// trendResult 走势查询结果
+type trendResult struct {
+	Title    string            `json:"title"`
+	Interval string            `json:"interval"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Series   []dbm.TrendSeries `json:"series"`
+}
+
+// GetTitleTrend 某条目在各数据源上的名次与热度走势
+// GET /api/public/trend?title=XXX[&source=weibo,zhihu][&interval=1h][&from=&to=]
+// interval 为 Go 时长格式（如 30m、1h、24h），from 默认为 to 之前 7 天，to 默认为当前时间
+func (h *Handler) GetTitleTrend(c *gin.Context) {
+	query, err := parseTrendQuery(c)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 400, Message: "参数错误：" + err.Error()})
+		return
+	}
+	series, err := h.deps.Trend.GetTitleTrend(c.Request.Context(), query)
+	if err != nil {
+		c.Error(&until.BusinessError{Code: 500, Message: "获取数据失败：" + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, until.Response{
+		Code:    0,
+		Message: "获取成功",
+		Data: trendResult{
+			Title:    query.Title,
+			Interval: query.Interval.String(),
+			From:     query.From,
+			To:       query.To,
+			Series:   series,
+		},
+	})
+}
+
+// parseTrendQuery 解析走势查询参数，时间桶数超过 maxTrendBuckets 时报错
+func parseTrendQuery(c *gin.Context) (dbm.TrendQuery, error) {
+	query := dbm.TrendQuery{Title: strings.TrimSpace(c.Query("title")), Interval: defaultTrendInterval, Location: config.ShanghaiLoc}
+	if query.Title == "" {
+		return query, errors.New("title不能为空")
+	}
+	for _, source := range c.QueryArray("source") {
+		for _, s := range strings.Split(source, ",") {
+			if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+				query.Sources = append(query.Sources, s)
+			}
+		}
+	}
+	if raw := c.Query("interval"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval < minTrendInterval {
+			return query, fmt.Errorf("interval 格式无效或小于 %s", minTrendInterval)
+		}
+		query.Interval = interval
+	}
+	var err error
+	if query.From, err = parseTimeParam(c.Query("from")); err != nil {
+		return query, fmt.Errorf("from %w", err)
+	}
+	if query.To, err = parseTimeParam(c.Query("to")); err != nil {
+		return query, fmt.Errorf("to %w", err)
+	}
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultTrendWindow)
+	}
+	if query.From.After(query.To) {
+		return query, errors.New("from 不能晚于 to")
+	}
+	if query.To.Sub(query.From)/query.Interval > maxTrendBuckets {
+		return query, fmt.Errorf("时间范围过大，最多 %d 个时间桶，请增大 interval 或缩小范围", maxTrendBuckets)
+	}
+	return query, nil
+}
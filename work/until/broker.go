@@ -0,0 +1,102 @@
+package until
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+)
+
+// MQ 后端（配置 mq_backend）
+const (
+	BrokerRabbitMQ     = "rabbitmq"      // RabbitMQ（发布确认 + 本地 outbox）
+	BrokerRedisStreams = "redis_streams" // Redis Streams（复用 RedisManger 主节点连接）
+	BrokerMemory       = "memory"        // 进程内优先级队列（单机部署、测试）
+)
+
+// Message 与具体 MQ 无关的消息
+type Message struct {
+	Body      []byte
+	Priority  uint8          // 优先级（队列声明了 MaxPriority 时生效，越大越先消费）
+	Headers   map[string]any // 消息头（如重试次数）
+	Timestamp time.Time
+}
+
+// Delivery 消费到的消息，处理完成后必须调用 Ack 或 Nack 之一（重复调用无效）
+type Delivery struct {
+	Message
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+func (d Delivery) Ack() error {
+	return d.ack()
+}
+
+// Nack 拒绝消息，requeue 为 true 时重新入队
+func (d Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
+}
+
+// settleOnce 保证 ack/nack 只生效一次，并在生效后执行 done（如释放预取配额）
+func settleOnce(ack func() error, nack func(requeue bool) error, done func()) (func() error, func(requeue bool) error) {
+	var once sync.Once
+	errSettled := fmt.Errorf("delivery already acknowledged")
+	wrapAck := func() error {
+		err := errSettled
+		once.Do(func() {
+			err = ack()
+			done()
+		})
+		return err
+	}
+	wrapNack := func(requeue bool) error {
+		err := errSettled
+		once.Do(func() {
+			err = nack(requeue)
+			done()
+		})
+		return err
+	}
+	return wrapAck, wrapNack
+}
+
+// QueueOptions 队列声明参数
+type QueueOptions struct {
+	MaxPriority uint8 // >0 表示优先级队列
+	// 延迟队列：消息在此队列停留 DelayTTL 后转投 DeadLetterTo 队列（用于延迟重试），不应直接消费
+	DelayTTL     time.Duration
+	DeadLetterTo string
+}
+
+// Broker 消息队列抽象，业务代码只依赖此接口
+type Broker interface {
+	// DeclareQueue 声明队列（幂等），需在发布/消费前调用
+	DeclareQueue(ctx context.Context, name string, opts QueueOptions) error
+	// Publish 发布消息（持久化），返回 nil 表示消息已被后端接收
+	Publish(ctx context.Context, queueName string, msg Message) error
+	// Consume 开始消费，prefetch 为最多未确认的消息数；ctx 取消后停止投递，
+	// 并在已投递的消息确认后关闭返回的通道（后端断线时自动恢复，通道保持可用）
+	Consume(ctx context.Context, queueName, consumerTag string, prefetch int) (<-chan Delivery, error)
+	Close() error
+}
+
+// NewBroker 按配置 mq_backend 创建消息队列后端（redis_streams 需要 redisManger）
+func NewBroker(cfg config.GlobalConfig, redisManger *dbm.RedisManger) (Broker, error) {
+	switch cfg.MQBackend {
+	case BrokerRabbitMQ:
+		return newRabbitBroker(cfg)
+	case BrokerRedisStreams:
+		if redisManger == nil {
+			return nil, fmt.Errorf("redis_streams broker requires redis")
+		}
+		return newRedisStreamBroker(redisManger.GetMasterClient()), nil
+	case BrokerMemory:
+		return newMemoryBroker(), nil
+	default:
+		return nil, fmt.Errorf("unknown mq backend %q", cfg.MQBackend)
+	}
+}
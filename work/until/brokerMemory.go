@@ -0,0 +1,191 @@
+package until
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryBroker 进程内消息队列：每个队列一个优先级堆（优先级高者先出，同优先级先进先出）
+// 消息不持久化，进程退出即丢失，适合单机部署和测试
+type memoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+	done   chan struct{}
+	once   sync.Once
+}
+
+type memoryQueue struct {
+	opts    QueueOptions
+	items   memoryHeap
+	seq     uint64
+	changed chan struct{} // 入队时关闭并替换，唤醒等待中的消费者
+}
+
+type memoryItem struct {
+	msg Message
+	seq uint64
+}
+
+type memoryHeap []memoryItem
+
+func (h memoryHeap) Len() int { return len(h) }
+func (h memoryHeap) Less(i, j int) bool {
+	if h[i].msg.Priority != h[j].msg.Priority {
+		return h[i].msg.Priority > h[j].msg.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h memoryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *memoryHeap) Push(x any)   { *h = append(*h, x.(memoryItem)) }
+func (h *memoryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+var _ Broker = (*memoryBroker)(nil)
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{
+		queues: make(map[string]*memoryQueue),
+		done:   make(chan struct{}),
+	}
+}
+
+func (b *memoryBroker) DeclareQueue(ctx context.Context, name string, opts QueueOptions) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if q, ok := b.queues[name]; ok {
+		q.opts = opts
+		return nil
+	}
+	b.queues[name] = &memoryQueue{opts: opts, changed: make(chan struct{})}
+	return nil
+}
+
+// Publish 入队；延迟队列的消息在 DelayTTL 后转投 DeadLetterTo
+func (b *memoryBroker) Publish(ctx context.Context, queueName string, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[queueName]
+	if !ok {
+		publishMetrics.Add("failed", 1)
+		return fmt.Errorf("queue %s not declared", queueName)
+	}
+	if q.opts.DelayTTL > 0 {
+		target := q.opts.DeadLetterTo
+		time.AfterFunc(q.opts.DelayTTL, func() {
+			select {
+			case <-b.done:
+			default:
+				_ = b.Publish(context.Background(), target, msg)
+			}
+		})
+		publishMetrics.Add("confirmed", 1)
+		return nil
+	}
+	if q.opts.MaxPriority == 0 {
+		msg.Priority = 0
+	}
+	b.push(q, msg)
+	publishMetrics.Add("confirmed", 1)
+	return nil
+}
+
+// push 入队并唤醒消费者（调用方持有 b.mu）
+func (b *memoryBroker) push(q *memoryQueue, msg Message) {
+	q.seq++
+	heap.Push(&q.items, memoryItem{msg: msg, seq: q.seq})
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// pop 出队，队列为空时返回等待通道
+func (b *memoryBroker) pop(queueName string) (Message, bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queues[queueName]
+	if q.items.Len() == 0 {
+		return Message{}, false, q.changed
+	}
+	return heap.Pop(&q.items).(memoryItem).msg, true, nil
+}
+
+func (b *memoryBroker) requeue(queueName string, msg Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.push(b.queues[queueName], msg)
+}
+
+func (b *memoryBroker) Consume(ctx context.Context, queueName, consumerTag string, prefetch int) (<-chan Delivery, error) {
+	b.mu.Lock()
+	_, ok := b.queues[queueName]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("queue %s not declared", queueName)
+	}
+
+	out := make(chan Delivery)
+	credits := make(chan struct{}, max(prefetch, 1)) // 未确认消息配额
+	var outstanding sync.WaitGroup
+	go func() {
+		defer close(out)
+		defer outstanding.Wait()
+		for {
+			select {
+			case credits <- struct{}{}:
+			case <-ctx.Done():
+				return
+			case <-b.done:
+				return
+			}
+
+			msg, ok, wait := b.pop(queueName)
+			for !ok {
+				select {
+				case <-wait:
+				case <-ctx.Done():
+					return
+				case <-b.done:
+					return
+				}
+				msg, ok, wait = b.pop(queueName)
+			}
+
+			outstanding.Add(1)
+			ack, nack := settleOnce(
+				func() error { return nil },
+				func(requeue bool) error {
+					if requeue {
+						b.requeue(queueName, msg)
+					}
+					return nil
+				},
+				func() {
+					<-credits
+					outstanding.Done()
+				},
+			)
+			delivery := Delivery{Message: msg, ack: ack, nack: nack}
+			select {
+			case out <- delivery:
+			case <-ctx.Done():
+				_ = delivery.Nack(true)
+				return
+			case <-b.done:
+				_ = delivery.Nack(true)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *memoryBroker) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return nil
+}
@@ -0,0 +1,230 @@
+package until
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// receive 从消费通道取一条消息，超时视为失败
+func receive(t *testing.T, msgs <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		if !ok {
+			t.Fatal("delivery channel closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	return Delivery{}
+}
+
+// expectNoDelivery 断言短时间内没有消息投递
+func expectNoDelivery(t *testing.T, msgs <-chan Delivery) {
+	t.Helper()
+	select {
+	case d := <-msgs:
+		t.Fatalf("unexpected delivery %q", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryBrokerPriorityOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newMemoryBroker()
+	defer b.Close()
+	if err := b.DeclareQueue(ctx, "jobs", QueueOptions{MaxPriority: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Message{
+		{Body: []byte("low-1"), Priority: 0},
+		{Body: []byte("high"), Priority: 10},
+		{Body: []byte("low-2"), Priority: 0},
+		{Body: []byte("mid"), Priority: 5},
+	} {
+		if err := b.Publish(ctx, "jobs", m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs, err := b.Consume(ctx, "jobs", "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 优先级高者先出，同优先级先进先出
+	for _, want := range []string{"high", "mid", "low-1", "low-2"} {
+		d := receive(t, msgs)
+		if string(d.Body) != want {
+			t.Fatalf("got %q, want %q", d.Body, want)
+		}
+		if err := d.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryBrokerIgnoresPriorityOnPlainQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newMemoryBroker()
+	defer b.Close()
+	if err := b.DeclareQueue(ctx, "logs", QueueOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Publish(ctx, "logs", Message{Body: []byte("first"), Priority: 0})
+	_ = b.Publish(ctx, "logs", Message{Body: []byte("second"), Priority: 9})
+
+	msgs, err := b.Consume(ctx, "logs", "test", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"first", "second"} {
+		d := receive(t, msgs)
+		if string(d.Body) != want {
+			t.Fatalf("got %q, want %q", d.Body, want)
+		}
+		_ = d.Ack()
+	}
+	if err := b.Publish(ctx, "missing", Message{}); err == nil {
+		t.Fatal("publish to undeclared queue should fail")
+	}
+}
+
+func TestMemoryBrokerNackRequeue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newMemoryBroker()
+	defer b.Close()
+	if err := b.DeclareQueue(ctx, "jobs", QueueOptions{MaxPriority: 10}); err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Publish(ctx, "jobs", Message{Body: []byte("retry-me")})
+	_ = b.Publish(ctx, "jobs", Message{Body: []byte("drop-me")})
+
+	msgs, err := b.Consume(ctx, "jobs", "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := receive(t, msgs)
+	if string(first.Body) != "retry-me" {
+		t.Fatalf("got %q, want retry-me", first.Body)
+	}
+	// prefetch=1：未确认前不会投递下一条
+	expectNoDelivery(t, msgs)
+	if err := first.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新入队的消息排在同优先级已有消息之后
+	second := receive(t, msgs)
+	if string(second.Body) != "drop-me" {
+		t.Fatalf("got %q, want drop-me", second.Body)
+	}
+	if err := second.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	again := receive(t, msgs)
+	if string(again.Body) != "retry-me" {
+		t.Fatalf("got %q, want requeued retry-me", again.Body)
+	}
+	if err := again.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	// 未重新入队的消息被丢弃
+	expectNoDelivery(t, msgs)
+}
+
+func TestMemoryBrokerDelayQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newMemoryBroker()
+	defer b.Close()
+	_ = b.DeclareQueue(ctx, "jobs", QueueOptions{})
+	_ = b.DeclareQueue(ctx, "jobs-retry", QueueOptions{DelayTTL: 30 * time.Millisecond, DeadLetterTo: "jobs"})
+
+	msgs, err := b.Consume(ctx, "jobs", "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := b.Publish(ctx, "jobs-retry", Message{Body: []byte("later")}); err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, msgs)
+	if string(d.Body) != "later" || time.Since(start) < 30*time.Millisecond {
+		t.Fatalf("got %q after %v, want delayed delivery", d.Body, time.Since(start))
+	}
+	_ = d.Ack()
+}
+
+func TestSettleOnce(t *testing.T) {
+	var acks, nacks, dones int
+	var requeued bool
+	ack, nack := settleOnce(
+		func() error { acks++; return nil },
+		func(requeue bool) error { nacks++; requeued = requeue; return nil },
+		func() { dones++ },
+	)
+	if err := ack(); err != nil {
+		t.Fatalf("first ack: %v", err)
+	}
+	if err := ack(); err == nil {
+		t.Fatal("second ack should fail")
+	}
+	if err := nack(true); err == nil {
+		t.Fatal("nack after ack should fail")
+	}
+	if acks != 1 || nacks != 0 || dones != 1 || requeued {
+		t.Fatalf("acks=%d nacks=%d dones=%d requeued=%v, want ack once", acks, nacks, dones, requeued)
+	}
+
+	// 底层确认失败时也只执行一次，并返回该错误
+	errAck := errors.New("channel closed")
+	ack, nack = settleOnce(
+		func() error { return errAck },
+		func(requeue bool) error { nacks++; return nil },
+		func() { dones++ },
+	)
+	if err := nack(false); err != nil {
+		t.Fatalf("first nack: %v", err)
+	}
+	if err := ack(); err == nil || errors.Is(err, errAck) {
+		t.Fatalf("ack after nack = %v, want already-settled error", err)
+	}
+	if nacks != 1 || dones != 2 {
+		t.Fatalf("nacks=%d dones=%d, want nack once", nacks, dones)
+	}
+}
+
+func TestMemoryBrokerDeliveryAckOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newMemoryBroker()
+	defer b.Close()
+	_ = b.DeclareQueue(ctx, "jobs", QueueOptions{})
+	_ = b.Publish(ctx, "jobs", Message{Body: []byte("one")})
+	_ = b.Publish(ctx, "jobs", Message{Body: []byte("two")})
+
+	msgs, err := b.Consume(ctx, "jobs", "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, msgs)
+	if err := d.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	// 重复确认无效：不会重新入队，也不会重复释放预取配额
+	if err := d.Nack(true); err == nil {
+		t.Fatal("nack after ack should fail")
+	}
+	next := receive(t, msgs)
+	if string(next.Body) != "two" {
+		t.Fatalf("got %q, want two", next.Body)
+	}
+	expectNoDelivery(t, msgs)
+	_ = next.Ack()
+}
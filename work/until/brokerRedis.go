@@ -0,0 +1,370 @@
+package until
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisStreamGroup       = "goweb"          // 所有实例共用的消费组
+	redisStreamMaxLen      = 100000           // 每个流保留的最大消息数（近似裁剪）
+	redisStreamBlock       = 2 * time.Second  // XREADGROUP 阻塞时间
+	redisStreamClaimIdle   = time.Minute      // 未确认超过此时间的消息可被其他消费者认领（如实例崩溃）
+	redisStreamClaimPeriod = 30 * time.Second // 认领检查间隔
+	redisDelayPollInterval = 500 * time.Millisecond
+)
+
+// redisStreamBroker 基于 Redis Streams 的消息队列
+// 每个队列一个流（优先级队列额外有一个高优先级流，priority>0 的消息进入高优先级流并优先消费），
+// 延迟队列用有序集合按到期时间保存消息，到期后转投目标队列
+// 键使用 {队列名} 哈希标签，保证 Cluster 模式下同一队列的键位于同一槽
+type redisStreamBroker struct {
+	client   redis.UniversalClient
+	consumer string // 消费组内的消费者名（主机名-进程号）
+
+	mu     sync.Mutex
+	queues map[string]QueueOptions
+
+	done    chan struct{}
+	once    sync.Once
+	workers sync.WaitGroup // 延迟队列转投协程
+}
+
+var _ Broker = (*redisStreamBroker)(nil)
+
+func newRedisStreamBroker(client redis.UniversalClient) *redisStreamBroker {
+	host, _ := os.Hostname()
+	return &redisStreamBroker{
+		client:   client,
+		consumer: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		queues:   make(map[string]QueueOptions),
+		done:     make(chan struct{}),
+	}
+}
+
+func redisStreamKey(queueName string) string     { return "mq:{" + queueName + "}:stream" }
+func redisStreamHighKey(queueName string) string { return "mq:{" + queueName + "}:high" }
+func redisDelayKey(queueName string) string      { return "mq:{" + queueName + "}:delay" }
+
+// redisStreams 队列对应的流（高优先级在前）
+func redisStreams(queueName string, opts QueueOptions) []string {
+	if opts.MaxPriority > 0 {
+		return []string{redisStreamHighKey(queueName), redisStreamKey(queueName)}
+	}
+	return []string{redisStreamKey(queueName)}
+}
+
+func (b *redisStreamBroker) DeclareQueue(ctx context.Context, name string, opts QueueOptions) error {
+	b.mu.Lock()
+	_, declared := b.queues[name]
+	b.queues[name] = opts
+	b.mu.Unlock()
+
+	if opts.DelayTTL > 0 {
+		if !declared {
+			b.workers.Add(1)
+			go b.moveDueMessages(name)
+		}
+		return nil
+	}
+	for _, stream := range redisStreams(name, opts) {
+		// 从流的起点创建消费组，已存在时忽略
+		err := b.client.XGroupCreateMkStream(ctx, stream, redisStreamGroup, "0").Err()
+		if err != nil && !isBusyGroup(err) {
+			return fmt.Errorf("create consumer group on %s failed: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+func isBusyGroup(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && len(redisErr.Error()) >= 9 && redisErr.Error()[:9] == "BUSYGROUP"
+}
+
+// redisStreamMessage 延迟队列中保存的消息（ID 保证相同内容的消息不会在有序集合中去重）
+type redisStreamMessage struct {
+	ID        string         `json:"id"`
+	Body      []byte         `json:"body"`
+	Priority  uint8          `json:"priority"`
+	Headers   map[string]any `json:"headers,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+func (b *redisStreamBroker) Publish(ctx context.Context, queueName string, msg Message) error {
+	b.mu.Lock()
+	opts, ok := b.queues[queueName]
+	b.mu.Unlock()
+	if !ok {
+		publishMetrics.Add("failed", 1)
+		return fmt.Errorf("queue %s not declared", queueName)
+	}
+
+	var err error
+	if opts.DelayTTL > 0 {
+		var data []byte
+		data, err = json.Marshal(redisStreamMessage{
+			ID: uuid.NewString(), Body: msg.Body, Priority: msg.Priority, Headers: msg.Headers, Timestamp: msg.Timestamp,
+		})
+		if err == nil {
+			dueAt := time.Now().Add(opts.DelayTTL).UnixMilli()
+			err = b.client.ZAdd(ctx, redisDelayKey(queueName), redis.Z{Score: float64(dueAt), Member: data}).Err()
+		}
+	} else {
+		err = b.add(ctx, queueName, opts, msg)
+	}
+	if err != nil {
+		publishMetrics.Add("failed", 1)
+		return fmt.Errorf("publish to %s failed: %w", queueName, err)
+	}
+	publishMetrics.Add("confirmed", 1)
+	return nil
+}
+
+// add 写入队列的流（priority>0 且为优先级队列时写入高优先级流）
+func (b *redisStreamBroker) add(ctx context.Context, queueName string, opts QueueOptions, msg Message) error {
+	stream := redisStreamKey(queueName)
+	if opts.MaxPriority > 0 && msg.Priority > 0 {
+		stream = redisStreamHighKey(queueName)
+	}
+	values := map[string]any{
+		"body":      msg.Body,
+		"priority":  int(msg.Priority),
+		"timestamp": msg.Timestamp.UnixMilli(),
+	}
+	if len(msg.Headers) > 0 {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return err
+		}
+		values["headers"] = headers
+	}
+	return b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: redisStreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+// moveDueMessages 把延迟队列中到期的消息转投目标队列
+// 先 ZREM 认领再 XADD，多实例同时轮询时每条消息只会被一个实例转投
+func (b *redisStreamBroker) moveDueMessages(queueName string) {
+	defer b.workers.Done()
+	ticker := time.NewTicker(redisDelayPollInterval)
+	defer ticker.Stop()
+	ctx := context.Background()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		b.mu.Lock()
+		opts := b.queues[queueName]
+		targetOpts, targetOK := b.queues[opts.DeadLetterTo]
+		b.mu.Unlock()
+		if !targetOK {
+			continue
+		}
+
+		due, err := b.client.ZRangeByScore(ctx, redisDelayKey(queueName), &redis.ZRangeBy{
+			Min: "-inf", Max: strconv.FormatInt(time.Now().UnixMilli(), 10), Count: 100,
+		}).Result()
+		if err != nil {
+			slog.Warn("读取延迟队列失败", "queue", queueName, "error", err)
+			continue
+		}
+		for _, member := range due {
+			claimed, err := b.client.ZRem(ctx, redisDelayKey(queueName), member).Result()
+			if err != nil || claimed == 0 {
+				continue
+			}
+			var stored redisStreamMessage
+			if err := json.Unmarshal([]byte(member), &stored); err != nil {
+				slog.Error("丢弃无法解析的延迟消息", "queue", queueName, "error", err)
+				continue
+			}
+			msg := Message{Body: stored.Body, Priority: stored.Priority, Headers: stored.Headers, Timestamp: stored.Timestamp}
+			if err := b.add(ctx, opts.DeadLetterTo, targetOpts, msg); err != nil {
+				// 转投失败：放回延迟队列稍后重试
+				slog.Warn("延迟消息转投失败", "queue", queueName, "target", opts.DeadLetterTo, "error", err)
+				_ = b.client.ZAdd(ctx, redisDelayKey(queueName), redis.Z{Score: float64(time.Now().UnixMilli()), Member: member}).Err()
+			}
+		}
+	}
+}
+
+func (b *redisStreamBroker) Consume(ctx context.Context, queueName, consumerTag string, prefetch int) (<-chan Delivery, error) {
+	b.mu.Lock()
+	opts, ok := b.queues[queueName]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("queue %s not declared", queueName)
+	}
+	prefetch = max(prefetch, 1)
+	consumer := b.consumer + "-" + consumerTag
+	streams := redisStreams(queueName, opts)
+
+	out := make(chan Delivery)
+	credits := make(chan struct{}, prefetch) // 未确认消息配额
+	go func() {
+		defer close(out)
+		var outstanding sync.WaitGroup
+		defer outstanding.Wait()
+
+		deliver := func(stream string, xmsg redis.XMessage) bool {
+			select {
+			case credits <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+			outstanding.Add(1)
+			delivery := b.newDelivery(queueName, opts, stream, xmsg, func() {
+				<-credits
+				outstanding.Done()
+			})
+			select {
+			case out <- delivery:
+				return true
+			case <-ctx.Done():
+				// 未交给消费者：留在待确认列表，由认领机制重新投递
+				<-credits
+				outstanding.Done()
+				return false
+			}
+		}
+
+		lastClaim := time.Time{}
+		for ctx.Err() == nil {
+			// 定期认领崩溃实例遗留的未确认消息
+			if time.Since(lastClaim) > redisStreamClaimPeriod {
+				lastClaim = time.Now()
+				for _, stream := range streams {
+					claimed, _, err := b.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+						Stream: stream, Group: redisStreamGroup, Consumer: consumer,
+						MinIdle: redisStreamClaimIdle, Start: "0-0", Count: int64(prefetch),
+					}).Result()
+					if err != nil && ctx.Err() == nil {
+						slog.Warn("认领未确认消息失败", "stream", stream, "error", err)
+					}
+					for _, xmsg := range claimed {
+						if !deliver(stream, xmsg) {
+							return
+						}
+					}
+				}
+			}
+
+			result, err := b.read(ctx, streams, consumer, int64(prefetch))
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Warn("读取消息流失败，稍后重试", "queue", queueName, "consumer", consumerTag, "error", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			for _, xstream := range result {
+				for _, xmsg := range xstream.Messages {
+					if !deliver(xstream.Stream, xmsg) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// read 优先非阻塞读取高优先级流，没有消息时阻塞读取全部流
+func (b *redisStreamBroker) read(ctx context.Context, streams []string, consumer string, count int64) ([]redis.XStream, error) {
+	if len(streams) > 1 {
+		result, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group: redisStreamGroup, Consumer: consumer,
+			Streams: []string{streams[0], ">"}, Count: count, Block: -1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if len(result) > 0 && len(result[0].Messages) > 0 {
+			return result, nil
+		}
+	}
+
+	args := make([]string, 0, len(streams)*2)
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	result, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: redisStreamGroup, Consumer: consumer,
+		Streams: args, Count: count, Block: redisStreamBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// newDelivery 转换流消息；Ack 确认并删除，Nack(requeue) 重新写入流后删除原消息
+func (b *redisStreamBroker) newDelivery(queueName string, opts QueueOptions, stream string, xmsg redis.XMessage, done func()) Delivery {
+	msg := Message{}
+	if body, ok := xmsg.Values["body"].(string); ok {
+		msg.Body = []byte(body)
+	}
+	if priority, ok := xmsg.Values["priority"].(string); ok {
+		p, _ := strconv.ParseUint(priority, 10, 8)
+		msg.Priority = uint8(p)
+	}
+	if ts, ok := xmsg.Values["timestamp"].(string); ok {
+		ms, _ := strconv.ParseInt(ts, 10, 64)
+		msg.Timestamp = time.UnixMilli(ms)
+	}
+	if headers, ok := xmsg.Values["headers"].(string); ok {
+		_ = json.Unmarshal([]byte(headers), &msg.Headers)
+	}
+
+	settle := func(ctx context.Context) error {
+		pipe := b.client.TxPipeline()
+		pipe.XAck(ctx, stream, redisStreamGroup, xmsg.ID)
+		pipe.XDel(ctx, stream, xmsg.ID)
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+	ack, nack := settleOnce(
+		func() error { return settle(context.Background()) },
+		func(requeue bool) error {
+			ctx := context.Background()
+			if requeue {
+				if err := b.add(ctx, queueName, opts, msg); err != nil {
+					return err
+				}
+			}
+			return settle(ctx)
+		},
+		done,
+	)
+	return Delivery{Message: msg, ack: ack, nack: nack}
+}
+
+func (b *redisStreamBroker) Close() error {
+	b.once.Do(func() { close(b.done) })
+	b.workers.Wait()
+	return nil
+}
@@ -0,0 +1,45 @@
+package until
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	EmailVerifyCodeTTL    = 24 * time.Hour   // 验证码有效期
+	EmailVerifyCooldown   = time.Minute      // 同一用户两次发送验证邮件的最小间隔
+	EmailVerifyRatePrefix = "notify:verify:" // 验证邮件发送冷却期（按用户）
+	emailVerifyCodeLen    = 10
+	emailVerifyAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // 去掉易混淆的 0/O、1/I
+)
+
+// NewEmailVerifyCode 生成邮箱验证码
+func NewEmailVerifyCode() (string, error) {
+	buf := make([]byte, emailVerifyCodeLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = emailVerifyAlphabet[int(b)%len(emailVerifyAlphabet)] // 字母表长度 32 整除 256，无偏差
+	}
+	return string(buf), nil
+}
+
+// HashEmailVerifyCode 验证码摘要（只保存摘要，不区分大小写、忽略首尾空白）
+func HashEmailVerifyCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// SendEmailVerifyCode 向订阅邮箱发送验证码，用户提交验证码后该邮箱才会收到提醒
+func SendEmailVerifyCode(ctx context.Context, mailer Mailer, to, subID, code string) error {
+	subject := "热榜订阅邮箱验证"
+	body := fmt.Sprintf("你正在为热榜订阅（%s）设置提醒邮箱，验证码为：\n\n%s\n\n验证码 %d 小时内有效。如非本人操作，请忽略本邮件，不会再收到任何提醒。\n",
+		subID, code, int(EmailVerifyCodeTTL.Hours()))
+	return mailer.SendMail(ctx, to, subject, body)
+}
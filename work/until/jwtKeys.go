@@ -0,0 +1,237 @@
+package until
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github/AHKLIC/Web/work/config"
+	"log/slog"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// jwtKey 单个签名/验签密钥
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod // RS256（RSA）或 EdDSA（Ed25519）
+	private crypto.Signer     // 私钥，仅验签的旧密钥为 nil
+	public  crypto.PublicKey
+}
+
+// jwtKeySet 当前生效的密钥集合（active 用于签名，keys 按 kid 用于验签）
+type jwtKeySet struct {
+	active *jwtKey
+	keys   map[string]*jwtKey
+}
+
+var (
+	jwtKeysMu sync.RWMutex
+	jwtKeys   *jwtKeySet
+)
+
+// InitJWTKeys 按配置加载 JWT 密钥（启动时调用一次；jwt 配置不参与热更新，轮换密钥需重启）
+// 未配置任何密钥且 jwt.allow_ephemeral 开启时生成临时 Ed25519 密钥，仅适用于本地开发（重启后旧令牌全部失效）
+// 临时密钥使用随机 kid，多实例误用时 JWKS 使用方可区分各实例的密钥
+func InitJWTKeys(cfg config.JWTConfig) error {
+	ks := &jwtKeySet{keys: make(map[string]*jwtKey)}
+
+	if len(cfg.Keys) == 0 {
+		if !cfg.AllowEphemeral {
+			return fmt.Errorf("no jwt keys configured and jwt.allow_ephemeral is disabled")
+		}
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generate ephemeral jwt key failed: %w", err)
+		}
+		key := &jwtKey{kid: "ephemeral-" + uuid.NewString()[:8], method: jwt.SigningMethodEdDSA, private: priv, public: pub}
+		ks.keys[key.kid] = key
+		ks.active = key
+		slog.Warn("未配置 JWT 密钥，使用临时 Ed25519 密钥（重启后令牌失效）")
+	} else {
+		for _, keyCfg := range cfg.Keys {
+			key, err := loadJWTKey(keyCfg)
+			if err != nil {
+				return fmt.Errorf("load jwt key %q failed: %w", keyCfg.Kid, err)
+			}
+			if _, exists := ks.keys[key.kid]; exists {
+				return fmt.Errorf("duplicate jwt key kid %q", key.kid)
+			}
+			ks.keys[key.kid] = key
+		}
+		active, ok := ks.keys[cfg.ActiveKid]
+		if !ok {
+			return fmt.Errorf("jwt active_kid %q not found in keys", cfg.ActiveKid)
+		}
+		if active.private == nil {
+			return fmt.Errorf("jwt active key %q has no private key", cfg.ActiveKid)
+		}
+		ks.active = active
+	}
+
+	jwtKeysMu.Lock()
+	jwtKeys = ks
+	jwtKeysMu.Unlock()
+	slog.Info("JWT 密钥加载成功", "active_kid", ks.active.kid, "alg", ks.active.method.Alg(), "key_count", len(ks.keys))
+	return nil
+}
+
+// loadJWTKey 解析单个密钥配置（有私钥时公钥从私钥推导）
+func loadJWTKey(cfg config.JWTKeyConfig) (*jwtKey, error) {
+	if cfg.Kid == "" {
+		return nil, fmt.Errorf("kid is empty")
+	}
+	key := &jwtKey{kid: cfg.Kid}
+
+	privPEM, err := readPEM(cfg.PrivateKeyFile, cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if privPEM != nil {
+		signer, err := parsePrivateKey(privPEM)
+		if err != nil {
+			return nil, err
+		}
+		key.private = signer
+		key.public = signer.Public()
+	} else {
+		pubPEM, err := readPEM(cfg.PublicKeyFile, cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if pubPEM == nil {
+			return nil, fmt.Errorf("neither private key nor public key configured")
+		}
+		pub, err := x509.ParsePKIXPublicKey(pubPEM.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key failed: %w", err)
+		}
+		key.public = pub
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (only RSA and Ed25519)", key.public)
+	}
+	return key, nil
+}
+
+// readPEM 读取 PEM 块（文件优先，其次内联内容；都未配置返回 nil）
+func readPEM(file, inline string) (*pem.Block, error) {
+	data := []byte(inline)
+	if file != "" {
+		var err error
+		data, err = os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read key file failed: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM data")
+	}
+	return block, nil
+}
+
+// parsePrivateKey 解析 PKCS#8 或 PKCS#1（RSA）私钥
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key failed: %w", err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+	return signer, nil
+}
+
+func currentJWTKeys() *jwtKeySet {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	return jwtKeys
+}
+
+// signJWT 使用当前生效密钥签名，并在头部写入 kid
+func signJWT(claims jwt.Claims) (string, error) {
+	ks := currentJWTKeys()
+	if ks == nil {
+		return "", fmt.Errorf("jwt keys not initialized")
+	}
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.kid
+	return token.SignedString(ks.active.private)
+}
+
+// jwtKeyFunc 按 kid 查找验签公钥，并校验签名算法与密钥类型一致
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	ks := currentJWTKeys()
+	if ks == nil {
+		return nil, fmt.Errorf("jwt keys not initialized")
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("未知的签名密钥：%q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("不支持的签名算法：%v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JWKS 返回全部验签公钥，供其他服务校验本服务签发的令牌
+func JWKS() []JWK {
+	ks := currentJWTKeys()
+	if ks == nil {
+		return []JWK{}
+	}
+	jwks := make([]JWK, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		jwks = append(jwks, jwk)
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
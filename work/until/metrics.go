@@ -0,0 +1,34 @@
+package until
+
+import "expvar"
+
+// publishMetrics MQ 发布结果计数（通过 /api/auth/admin/debug/vars 暴露，需 admin 角色）
+//
+//	confirmed        broker 已确认
+//	nacked           broker 拒绝
+//	returned         无法路由被退回
+//	timeout          等待确认超时
+//	failed           未连接或信道错误
+//	outbox_enqueued  写入 outbox 等待重试
+//	outbox_delivered 从 outbox 重试成功
+//	outbox_dropped   outbox 已满、消息损坏、被退回或超过重试次数而丢弃（后两者移入 outbox 的 dead 目录）
+//	outbox_pending   outbox 当前积压数
+var publishMetrics = expvar.NewMap("mq_publish")
+
+func init() {
+	publishMetrics.Set("outbox_pending", expvar.Func(func() any {
+		client, ok := broker.(*mqClient)
+		if !ok || client.outbox == nil {
+			return 0
+		}
+		return client.outbox.len()
+	}))
+}
+
+// notifyMetrics 订阅提醒计数（通过 /api/auth/admin/debug/vars 暴露，需 admin 角色）
+//
+//	email_sent / email_failed     邮件发送成功 / 失败
+//	webhook_sent / webhook_failed 回调成功 / 失败
+//	deduped                       去重期内已提醒而跳过的条目
+//	rate_limited                  冷却期内推迟的提醒
+var notifyMetrics = expvar.NewMap("notify")
@@ -0,0 +1,471 @@
+package until
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	publishChannelPoolSize = 8                      // 发布信道池容量
+	mqReconnectMinDelay    = 500 * time.Millisecond // 重连初始退避
+	mqReconnectMaxDelay    = 30 * time.Second       // 重连最大退避
+)
+
+var (
+	errMQClosed        = errors.New("mq client closed")
+	errMQNotConnected  = errors.New("mq not connected")
+	errPublishNacked   = errors.New("mq publish nacked by broker")
+	errPublishReturned = errors.New("mq publish returned by broker")
+)
+
+// mqClient 可自动重连的 RabbitMQ 客户端（Broker 的 RabbitMQ 实现）
+// 发布者从信道池借用信道，每个消费者独占一个信道（amqp091 信道不支持并发使用）
+type mqClient struct {
+	url string
+
+	queuesMu sync.Mutex
+	queues   []queueDeclaration // 已声明的队列（重连后重新声明）
+
+	mu    sync.RWMutex
+	conn  *amqp091.Connection
+	ready chan struct{} // 已连接时处于关闭状态；断线后替换为新的未关闭通道
+
+	pubPool        chan *pubChannel
+	confirmTimeout time.Duration // 等待 broker 确认的超时时间
+	outbox         *outbox       // 未确认消息的本地暂存（nil 表示不启用）
+
+	done chan struct{} // close 后关闭，停止重连、消费者和 outbox 重试
+	once sync.Once
+}
+
+var _ Broker = (*mqClient)(nil)
+
+// newRabbitBroker 连接 RabbitMQ，之后连接断开会自动重连
+// 发布失败的消息暂存到本地 outbox，由后台协程按序重试
+func newRabbitBroker(cfg config.GlobalConfig) (*mqClient, error) {
+	box, err := openOutbox(cfg.MQPublish.OutboxDir, cfg.MQPublish.OutboxMaxMessages, cfg.MQPublish.OutboxMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	client := newMQClient(cfg.RabbitMQURL, cfg.MQPublish.ConfirmTimeout.Duration, box)
+	conn, err := client.dial()
+	if err != nil {
+		return nil, err
+	}
+	client.setConnection(conn)
+	go client.supervise()
+	go client.relay(cfg.MQPublish.OutboxRetryInterval.Duration)
+	return client, nil
+}
+
+func newMQClient(url string, confirmTimeout time.Duration, outbox *outbox) *mqClient {
+	return &mqClient{
+		url:            url,
+		ready:          make(chan struct{}),
+		pubPool:        make(chan *pubChannel, publishChannelPoolSize),
+		confirmTimeout: confirmTimeout,
+		outbox:         outbox,
+		done:           make(chan struct{}),
+	}
+}
+
+// dial 建立连接并重新声明已声明过的队列
+func (m *mqClient) dial() (*amqp091.Connection, error) {
+	conn, err := amqp091.DialConfig(m.url, amqp091.Config{
+		Heartbeat: 10 * time.Second, // 心跳间隔
+		Locale:    "en_US",          // 本地化设置
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mq connect failed: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("create mq channel failed: %w", err)
+	}
+	defer ch.Close()
+
+	m.queuesMu.Lock()
+	queues := slices.Clone(m.queues)
+	m.queuesMu.Unlock()
+	for _, queue := range queues {
+		if err := declareRabbitQueue(ch, queue.name, queue.opts); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// declareRabbitQueue 声明持久化、非自动删除、非排他队列
+// 延迟队列通过消息 TTL + 死信交换机实现：到期后经默认交换机转投 DeadLetterTo
+func declareRabbitQueue(ch *amqp091.Channel, name string, opts QueueOptions) error {
+	args := amqp091.Table{}
+	if opts.MaxPriority > 0 {
+		args["x-max-priority"] = int32(opts.MaxPriority)
+	}
+	if opts.DelayTTL > 0 {
+		args["x-message-ttl"] = opts.DelayTTL.Milliseconds()
+		args["x-dead-letter-exchange"] = "" // 默认交换机
+		args["x-dead-letter-routing-key"] = opts.DeadLetterTo
+	}
+	if _, err := ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return fmt.Errorf("declare queue %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// DeclareQueue 立即声明队列，并记录下来以便重连后重新声明
+func (m *mqClient) DeclareQueue(ctx context.Context, name string, opts QueueOptions) error {
+	ch, err := m.channel(ctx)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := declareRabbitQueue(ch, name, opts); err != nil {
+		return err
+	}
+	m.queuesMu.Lock()
+	defer m.queuesMu.Unlock()
+	m.queues = slices.DeleteFunc(m.queues, func(q queueDeclaration) bool { return q.name == name })
+	m.queues = append(m.queues, queueDeclaration{name: name, opts: opts})
+	return nil
+}
+
+// Publish 发布持久化消息（等待确认，失败时写入 outbox）
+func (m *mqClient) Publish(ctx context.Context, queueName string, msg Message) error {
+	return m.publish(ctx, queueName, amqp091.Publishing{
+		Headers:      amqp091.Table(msg.Headers),
+		DeliveryMode: amqp091.Persistent, // 消息持久化
+		ContentType:  "text/plain",       // 消息类型
+		Body:         msg.Body,
+		Timestamp:    msg.Timestamp,
+		Priority:     msg.Priority,
+	})
+}
+
+// Consume 在独占信道上消费，信道断开后自动重新订阅
+func (m *mqClient) Consume(ctx context.Context, queueName, consumerTag string, prefetch int) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		m.consume(ctx, consumerTag, prefetch, func(ctx context.Context, ch *amqp091.Channel) error {
+			return forwardRabbitDeliveries(ctx, ch, queueName, consumerTag, out)
+		})
+	}()
+	return out, nil
+}
+
+// forwardRabbitDeliveries 订阅队列并把消息转交给 out
+// ctx 取消时停止订阅，等已转交的消息全部确认后返回（返回后信道关闭，未确认的消息由 RabbitMQ 重新投递）
+func forwardRabbitDeliveries(ctx context.Context, ch *amqp091.Channel, queueName, consumerTag string, out chan<- Delivery) error {
+	msgs, err := ch.Consume(
+		queueName,
+		consumerTag,
+		false, // 手动确认
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("consume %s failed: %w", queueName, err)
+	}
+
+	var outstanding sync.WaitGroup
+	for {
+		select {
+		case <-ctx.Done():
+			if err := ch.Cancel(consumerTag, false); err != nil {
+				slog.Warn("取消消费者失败", "consumer", consumerTag, "error", err)
+			}
+			outstanding.Wait()
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return fmt.Errorf("consumer %s channel closed", consumerTag)
+			}
+			outstanding.Add(1)
+			ack, nack := settleOnce(
+				func() error { return msg.Ack(false) },
+				func(requeue bool) error { return msg.Nack(false, requeue) },
+				outstanding.Done,
+			)
+			delivery := Delivery{
+				Message: Message{Body: msg.Body, Priority: msg.Priority, Headers: msg.Headers, Timestamp: msg.Timestamp},
+				ack:     ack,
+				nack:    nack,
+			}
+			select {
+			case out <- delivery:
+			case <-ctx.Done():
+				_ = delivery.Nack(true)
+			}
+		}
+	}
+}
+
+// Close 停止重连并关闭连接
+func (m *mqClient) Close() error {
+	return m.close()
+}
+
+// setConnection 切换到新连接并唤醒等待中的发布者和消费者
+func (m *mqClient) setConnection(conn *amqp091.Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conn = conn
+	close(m.ready)
+}
+
+// supervise 监听连接关闭事件，断线后按指数退避重连
+func (m *mqClient) supervise() {
+	for {
+		m.mu.RLock()
+		conn := m.conn
+		m.mu.RUnlock()
+
+		closeCh := conn.NotifyClose(make(chan *amqp091.Error, 1))
+		select {
+		case <-m.done:
+			return
+		case amqpErr := <-closeCh:
+			slog.Error("MQ 连接断开，开始重连", "error", amqpErr)
+		}
+
+		m.mu.Lock()
+		m.conn = nil
+		m.ready = make(chan struct{})
+		m.mu.Unlock()
+
+		delay := mqReconnectMinDelay
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-time.After(delay):
+			}
+			conn, err := m.dial()
+			if err == nil {
+				m.setConnection(conn)
+				slog.Info("MQ 重连成功")
+				break
+			}
+			slog.Warn("MQ 重连失败", "error", err, "retry_in", delay)
+			delay = min(delay*2, mqReconnectMaxDelay)
+		}
+	}
+}
+
+// connection 返回可用连接，重连期间阻塞等待至 ctx 到期
+func (m *mqClient) connection(ctx context.Context) (*amqp091.Connection, error) {
+	for {
+		m.mu.RLock()
+		conn, ready := m.conn, m.ready
+		m.mu.RUnlock()
+		if conn != nil && !conn.IsClosed() {
+			return conn, nil
+		}
+		if conn != nil {
+			// 连接已断开但 supervise 尚未处理关闭事件，稍后重试
+			ready = nil
+		}
+		select {
+		case <-ready:
+		case <-time.After(100 * time.Millisecond):
+		case <-m.done:
+			return nil, errMQClosed
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for mq connection: %w", ctx.Err())
+		}
+	}
+}
+
+// channel 在当前连接上新建信道
+func (m *mqClient) channel(ctx context.Context) (*amqp091.Channel, error) {
+	conn, err := m.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("create mq channel failed: %w", err)
+	}
+	return ch, nil
+}
+
+// pubChannel 处于确认模式的发布信道（同一时间只被一个发布者借用，退回消息可与本次发布对应）
+type pubChannel struct {
+	ch      *amqp091.Channel
+	returns chan amqp091.Return
+}
+
+// publish 发布消息并等待 broker 确认；失败或 outbox 中仍有积压时写入 outbox 按序重试
+// 写入 outbox 即视为发布成功（至少投递一次，超时后重试可能产生重复消息）
+// 被退回的消息（目标队列不存在）重试也不会成功，直接返回错误
+func (m *mqClient) publish(ctx context.Context, queueName string, msg amqp091.Publishing) error {
+	if m.outbox == nil {
+		return m.publishConfirmed(ctx, queueName, msg)
+	}
+	if m.outbox.len() == 0 {
+		err := m.publishConfirmed(ctx, queueName, msg)
+		if err == nil || errors.Is(err, errPublishReturned) {
+			return err
+		}
+		slog.Warn("MQ 发布失败，写入 outbox 稍后重试", "queue", queueName, "error", err)
+	}
+	if err := m.outbox.enqueue(newOutboxMessage(queueName, msg)); err != nil {
+		publishMetrics.Add("outbox_dropped", 1)
+		return fmt.Errorf("mq publish failed and outbox unavailable: %w", err)
+	}
+	publishMetrics.Add("outbox_enqueued", 1)
+	return nil
+}
+
+// publishConfirmed 借用发布信道发送 mandatory 消息，在 confirmTimeout 内等待确认
+func (m *mqClient) publishConfirmed(ctx context.Context, queueName string, msg amqp091.Publishing) error {
+	ctx, cancel := context.WithTimeout(ctx, m.confirmTimeout)
+	defer cancel()
+
+	pc, err := m.borrowChannel()
+	if err != nil {
+		publishMetrics.Add("failed", 1)
+		return err
+	}
+	confirm, err := pc.ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		"",        // 默认交换机
+		queueName, // 队列名（路由键）
+		true,      // mandatory: 消息无法路由时由 broker 退回
+		false,     // immediate: 无消费者时是否立即返回（AMQP 0-9-1 已废弃，仅兼容）
+		msg,
+	)
+	if err != nil {
+		_ = pc.ch.Close()
+		publishMetrics.Add("failed", 1)
+		return fmt.Errorf("mq publish failed: %w", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		// 确认结果未知：关闭信道，避免迟到的确认/退回被算到下一条消息上
+		_ = pc.ch.Close()
+		publishMetrics.Add("timeout", 1)
+		return fmt.Errorf("wait mq publish confirm: %w", err)
+	}
+	// broker 先发送 basic.return 再发送确认，此时退回消息（若有）已在通道中
+	select {
+	case ret := <-pc.returns:
+		m.returnChannel(pc)
+		publishMetrics.Add("returned", 1)
+		return fmt.Errorf("%w: %d %s", errPublishReturned, ret.ReplyCode, ret.ReplyText)
+	default:
+	}
+	m.returnChannel(pc)
+	if !acked {
+		publishMetrics.Add("nacked", 1)
+		return errPublishNacked
+	}
+	publishMetrics.Add("confirmed", 1)
+	return nil
+}
+
+// borrowChannel 从池中借用发布信道，池空时新建；未连接时立即失败（由 outbox 兜底）
+func (m *mqClient) borrowChannel() (*pubChannel, error) {
+	for {
+		select {
+		case pc := <-m.pubPool:
+			if !pc.ch.IsClosed() {
+				return pc, nil
+			}
+			// 旧连接上的信道，丢弃
+		default:
+			m.mu.RLock()
+			conn := m.conn
+			m.mu.RUnlock()
+			if conn == nil || conn.IsClosed() {
+				return nil, errMQNotConnected
+			}
+			ch, err := conn.Channel()
+			if err != nil {
+				return nil, fmt.Errorf("create mq channel failed: %w", err)
+			}
+			if err := ch.Confirm(false); err != nil {
+				_ = ch.Close()
+				return nil, fmt.Errorf("enable mq confirm mode failed: %w", err)
+			}
+			return &pubChannel{ch: ch, returns: ch.NotifyReturn(make(chan amqp091.Return, 1))}, nil
+		}
+	}
+}
+
+func (m *mqClient) returnChannel(pc *pubChannel) {
+	if pc.ch.IsClosed() {
+		return
+	}
+	select {
+	case m.pubPool <- pc:
+	default:
+		_ = pc.ch.Close()
+	}
+}
+
+// consume 在独占信道上运行消费者，连接断开后等待重连并重新启动，直到 ctx 取消
+// handle 在 deliveries 关闭（信道断开）或 ctx 取消时返回
+func (m *mqClient) consume(ctx context.Context, name string, prefetch int, handle func(ctx context.Context, ch *amqp091.Channel) error) {
+	for {
+		err := m.consumeOnce(ctx, prefetch, handle)
+		if ctx.Err() != nil || errors.Is(err, errMQClosed) {
+			return
+		}
+		slog.Warn("消费者信道断开，等待重连后重启", "consumer", name, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.done:
+			return
+		case <-time.After(mqReconnectMinDelay):
+		}
+	}
+}
+
+func (m *mqClient) consumeOnce(ctx context.Context, prefetch int, handle func(ctx context.Context, ch *amqp091.Channel) error) error {
+	ch, err := m.channel(ctx)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return fmt.Errorf("set mq qos failed: %w", err)
+	}
+	return handle(ctx, ch)
+}
+
+// close 停止重连并关闭信道池和连接
+func (m *mqClient) close() error {
+	m.once.Do(func() { close(m.done) })
+	for drained := false; !drained; {
+		select {
+		case pc := <-m.pubPool:
+			_ = pc.ch.Close()
+		default:
+			drained = true
+		}
+	}
+
+	m.mu.RLock()
+	conn := m.conn
+	m.mu.RUnlock()
+	if conn == nil || conn.IsClosed() {
+		return nil
+	}
+	return conn.Close()
+}
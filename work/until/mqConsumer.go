@@ -3,158 +3,227 @@ package until
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github/AHKLIC/Web/work/config"
 	"github/AHKLIC/Web/work/dbm"
 	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+)
+
+// ConsumerDeps 消费者依赖的存储
+type ConsumerDeps struct {
+	Fuzzy  dbm.FuzzySearchStore // 模糊检索数据源
+	Cache  dbm.CacheStore       // 结果缓存
+	Events dbm.EventBus         // 进度/完成事件推送
+}
 
-	"github.com/rabbitmq/amqp091-go"
+const (
+	accessLogConsumerTag  = "access-log-consumer"
+	fuzzyQueryConsumerTag = "fuzzy-query-consumer"
+	accessLogPrefetch     = 100 // 访问日志消费者预取数量
 )
 
-// StartMQConsumers 启动所有 MQ 消费者（程序启动时调用）
-func StartMQConsumers(ctx context.Context) {
+// consumerWG 运行中的消费者（含处理中的消息），退出时等待其确认完成
+var consumerWG sync.WaitGroup
+
+// StartMQConsumers 启动所有 MQ 消费者（程序启动时调用），ctx 取消后消费者停止接收新消息
+func StartMQConsumers(ctx context.Context, deps ConsumerDeps) error {
+	if broker == nil {
+		return errors.New("mq broker not initialized")
+	}
 	// // 1. 启动无效键清理消费者
 	// go startInvalidKeyConsumer(context.Background(), redisClient)
-	// 2. 启动访问日志消费者
-	go startAccessLogConsumer(ctx)
-	go startFuzzyQueryConsumer(ctx)
+	// 2. 启动访问日志消费者（后端断线重连由 Broker 负责，消费通道保持可用）
+	accessLogs, err := broker.Consume(ctx, AccessLogQueueName, accessLogConsumerTag, accessLogPrefetch)
+	if err != nil {
+		return fmt.Errorf("启动访问日志消费者失败: %w", err)
+	}
+	concurrency := config.GetGlobalConfig().FuzzyQueryConcurrency
+	fuzzyQueries, err := broker.Consume(ctx, FuzzyQueueName, fuzzyQueryConsumerTag, concurrency)
+	if err != nil {
+		return fmt.Errorf("启动模糊查询消费者失败: %w", err)
+	}
+
+	consumerWG.Add(2)
+	go func() {
+		defer consumerWG.Done()
+		startAccessLogConsumer(accessLogs)
+	}()
+	go func() {
+		defer consumerWG.Done()
+		startFuzzyQueryConsumer(ctx, fuzzyQueries, deps, concurrency)
+	}()
 	// 3. 启动数据更新消费者
 	// go startDataUpdateConsumer(context.Background(), redisClient)
 
-	slog.Info("所有 MQ 消费者启动成功（amqp091-go）")
+	slog.Info("所有 MQ 消费者启动成功", "backend", config.GetGlobalConfig().MQBackend)
+	return nil
 }
 
-// 2. 访问日志消费者：异步记录日志
-func startAccessLogConsumer(ctx context.Context) {
-	msgs, err := mqChannel.Consume(
-		AccessLogQueueName,
-		"access-log-consumer",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		slog.Error("启动访问日志消费者失败", "error", err)
-	}
+// WaitMQConsumers 等待消费者退出且处理中的消息全部确认（取消 StartMQConsumers 的 ctx 后、关闭 MQ 前调用）
+// 未读取的预取消息由后端重新投递
+func WaitMQConsumers(ctx context.Context) error {
+	return waitGroupWait(ctx, &consumerWG)
+}
+
+// 2. 访问日志消费者：异步记录日志（消费通道关闭时退出）
+func startAccessLogConsumer(msgs <-chan Delivery) {
 	slog.Info("启动访问日志消费者成功")
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("访问日志消费者退出")
-			return
-		case msg, ok := <-msgs:
-			if !ok {
-				slog.Error("访问日志消费者异常退出")
-				return
-			}
-			var logData LogLayout
-			if err := json.Unmarshal(msg.Body, &logData); err != nil {
-				slog.Error("failed to unmarshal access log", "error", err)
-			}
-			if logData.Status == 200 {
-				// 结构化输出，避免转义
-				slog.Info("access log",
-					"method", logData.Method,
-					"path", logData.Path,
-					"query", logData.Query,
-					"ip", logData.IP,
-					"user_agent", logData.UserAgent,
-					"error", logData.Error,
-					"cost", logData.Cost,
-					"status", logData.Status,
-				)
-			} else {
-				slog.Error("access log",
-					"method", logData.Method,
-					"path", logData.Path,
-					"query", logData.Query,
-					"ip", logData.IP,
-					"user_agent", logData.UserAgent,
-					"error", logData.Error,
-					"cost", logData.Cost,
-					"status", logData.Status,
-				)
-			}
-			_ = msg.Ack(false)
+	for msg := range msgs {
+		var logData LogLayout
+		if err := json.Unmarshal(msg.Body, &logData); err != nil {
+			slog.Error("failed to unmarshal access log", "error", err)
 		}
+		if logData.Status == 200 {
+			// 结构化输出，避免转义
+			slog.Info("access log",
+				"method", logData.Method,
+				"path", logData.Path,
+				"query", logData.Query,
+				"ip", logData.IP,
+				"user_agent", logData.UserAgent,
+				"error", logData.Error,
+				"cost", logData.Cost,
+				"status", logData.Status,
+			)
+		} else {
+			slog.Error("access log",
+				"method", logData.Method,
+				"path", logData.Path,
+				"query", logData.Query,
+				"ip", logData.IP,
+				"user_agent", logData.UserAgent,
+				"error", logData.Error,
+				"cost", logData.Cost,
+				"status", logData.Status,
+			)
+		}
+		_ = msg.Ack()
 	}
+	slog.Info("访问日志消费者退出")
 }
 
 // startFuzzyQueryConsumer 模糊查询消费者（异步查 DB + 写缓存）实现削峰
-func startFuzzyQueryConsumer(ctx context.Context) {
-	// 注册消费者
-	redisClient := dbm.AllDbManger.RedisManger.GetMasterClient()
-	msgs, err := mqChannel.Consume(
-		FuzzyQueueName,
-		"fuzzy-query-consumer",
-		false, // 手动确认
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		slog.Error("启动模糊查询消费者失败", "error", err)
-	}
-
-	// 并发控制（削峰：限制 8 个并发查询 DB）
-	concurrency := 8
+// ctx 取消后中断处理中的查询并将消息重新入队，等待全部消息处理完再返回
+func startFuzzyQueryConsumer(ctx context.Context, msgs <-chan Delivery, deps ConsumerDeps, concurrency int) {
+	// 并发控制（削峰：限制并发查询 DB 的数量）
 	sem := make(chan struct{}, concurrency)
 
 	slog.Info("模糊查询消费者启动成功", "并发处理数", concurrency)
 
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("模糊查询消费者退出")
+	// 查询随 ctx 取消而中止；重试、死信和确认使用不随 ctx 取消的上下文，保证退出时消息不丢失
+	procCtx := context.WithoutCancel(ctx)
+	var inflight sync.WaitGroup
+	for msg := range msgs {
+		sem <- struct{}{} // 占用信号量
+		inflight.Add(1)
+		go func(msg Delivery) {
+			defer inflight.Done()
+			defer func() { <-sem }() // 释放信号量
+			handleFuzzyQueryMessage(ctx, procCtx, deps, msg)
+		}(msg)
+	}
+	inflight.Wait()
+	slog.Info("模糊查询消费者退出")
+}
+
+// handleFuzzyQueryMessage 处理单条模糊查询消息；查询失败或 panic 时转入延迟重试/死信队列后确认原消息
+// ctx 取消（优雅退出）中断的查询重新入队，不计入重试次数；procCtx 用于重试、死信等收尾操作
+func handleFuzzyQueryMessage(ctx, procCtx context.Context, deps ConsumerDeps, msg Delivery) {
+	// 解析消息（检索条件 JSON，格式错误无法重试，直接进入死信队列）
+	var query dbm.FuzzyQuery
+	if err := json.Unmarshal(msg.Body, &query); err != nil || strings.TrimSpace(query.Keyword) == "" {
+		if err == nil {
+			err = errors.New("keyword is empty")
+		}
+		slog.Error("解析模糊查询消息失败:", "error", err)
+		deadLetterFuzzyQuery(procCtx, deps, msg, dbm.FuzzyQuery{}, fmt.Errorf("invalid message: %w", err))
+		return
+	}
+	query = query.Normalize()
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("模糊查询消费者", "panic:", r)
+			retryFuzzyQuery(procCtx, deps, msg, query, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	if err := ProcessFuzzyQuery(ctx, deps, query); err != nil {
+		if ctx.Err() != nil {
+			slog.Info("退出时中断模糊查询，消息重新入队", "keyword", query.Keyword)
+			_ = msg.Nack(true)
 			return
-		case msg, ok := <-msgs:
-			if !ok {
-				return
-			}
-
-			sem <- struct{}{} // 占用信号量
-			go func(msg amqp091.Delivery) {
-				defer func() {
-					<-sem // 释放信号量
-					if r := recover(); r != nil {
-						slog.Error("模糊查询消费者", "panic:", r)
-						_ = msg.Nack(false, true) //丢弃
-					}
-				}()
-
-				// 解析消息
-				var msgData map[string]string
-				if err := json.Unmarshal(msg.Body, &msgData); err != nil {
-					slog.Error("解析模糊查询消息失败:", "error", err)
-					_ = msg.Ack(false)
-					return
-				}
-				keyword := msgData["keyword"]
-				cacheKey := GetFuzzyCacheKey(keyword)
-				slog.Info("开始模糊查询", "keyword:", keyword)
-				resultList, err := dbm.AllDbManger.MongoManger.GetMongoDataFuzzyByKeyword(keyword)
-				if err != nil {
-					slog.Error("模糊查询数据库失败", "keyword", keyword, "error", err)
-				}
-
-				// 2. 结果序列化
-				resultJSON, _ := json.Marshal(resultList)
-				slog.Info("模糊查询成功", "keyword:", keyword)
-
-				// 3. 写入 Redis 缓存（状态改为 ready）
-				redisClient.HSet(
-					ctx, cacheKey,
-					"status", "ready",
-					"data", string(resultJSON),
-					"update_time", time.Now().Format("2006-01-02 15:04:05"),
-				)
-				redisClient.Expire(ctx, cacheKey, FuzzyCacheExpire) // 10 分钟过期
-
-				// 4. 确认消息
-				_ = msg.Ack(false)
-			}(msg)
 		}
+		retryFuzzyQuery(procCtx, deps, msg, query, err)
+		return
+	}
+
+	// 4. 确认消息
+	_ = msg.Ack()
+}
+
+// FuzzyEvent 模糊查询进度/完成事件（发布到 GetFuzzyEventChannel 频道，由 SSE/WebSocket 推送给客户端）
+type FuzzyEvent struct {
+	Status     string `json:"status"`               // loading/ready/failed
+	Progress   int    `json:"progress"`             // 0-100，按已检索完成的集合数计算
+	Collection string `json:"collection,omitempty"` // 刚检索完成的集合
+	Error      string `json:"error,omitempty"`      // 失败原因（status=failed）
+}
+
+// publishFuzzyEvent 发布模糊查询事件（失败只记录日志，客户端仍可轮询缓存）
+func publishFuzzyEvent(ctx context.Context, deps ConsumerDeps, cacheKey string, event FuzzyEvent) {
+	payload, _ := json.Marshal(event)
+	if err := deps.Events.PublishEvent(ctx, GetFuzzyEventChannel(cacheKey), payload); err != nil {
+		slog.Warn("发布模糊查询事件失败", "status", event.Status, "error", err)
+	}
+}
+
+// ProcessFuzzyQuery 执行一次模糊查询并写入缓存（状态改为 ready），查询失败时返回错误且不写结果
+// 每检索完一个集合更新缓存中的 progress 并发布进度事件，写入结果后发布 ready 事件
+func ProcessFuzzyQuery(ctx context.Context, deps ConsumerDeps, query dbm.FuzzyQuery) error {
+	cacheKey := GetFuzzyCacheKey(query)
+	keyword := query.Keyword
+	slog.Info("开始模糊查询", "keyword:", keyword, "sources", query.Sources, "sort", query.Sort)
+	reportProgress := func(done, total int, collection string) {
+		progress := 0
+		if total > 0 {
+			progress = done * 100 / total
+		}
+		if err := deps.Cache.SetHash(ctx, cacheKey, map[string]string{
+			"progress": strconv.Itoa(progress),
+		}, FuzzyCacheExpire); err != nil {
+			slog.Warn("更新模糊查询进度失败", "keyword", keyword, "error", err)
+		}
+		publishFuzzyEvent(ctx, deps, cacheKey, FuzzyEvent{Status: "loading", Progress: progress, Collection: collection})
+	}
+	reportProgress(0, 0, "") // 重试时进度从 0 开始
+
+	resultList, err := deps.Fuzzy.GetMongoDataFuzzyByKeyword(ctx, query, reportProgress)
+	if err != nil {
+		slog.Error("模糊查询数据库失败", "keyword", keyword, "error", err)
+		return fmt.Errorf("fuzzy query failed: %w", err)
+	}
+
+	// 2. 结果序列化
+	resultJSON, err := json.Marshal(resultList)
+	if err != nil {
+		return fmt.Errorf("marshal fuzzy result failed: %w", err)
+	}
+	slog.Info("模糊查询成功", "keyword:", keyword)
+
+	// 3. 写入缓存（10 分钟过期）
+	if err := deps.Cache.SetHash(ctx, cacheKey, map[string]string{
+		"status":      "ready",
+		"data":        string(resultJSON),
+		"progress":    "100",
+		"update_time": time.Now().Format("2006-01-02 15:04:05"),
+	}, FuzzyCacheExpire); err != nil {
+		return fmt.Errorf("write fuzzy cache failed: %w", err)
 	}
+	publishFuzzyEvent(ctx, deps, cacheKey, FuzzyEvent{Status: "ready", Progress: 100})
+	return nil
 }
@@ -0,0 +1,56 @@
+package until
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github/AHKLIC/Web/work/dbm"
+)
+
+// recordDelivery 记录确认结果的消息
+func recordDelivery(t *testing.T, query dbm.FuzzyQuery, settled *string) Delivery {
+	t.Helper()
+	body, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Delivery{
+		Message: Message{Body: body},
+		ack:     func() error { *settled = "ack"; return nil },
+		nack: func(requeue bool) error {
+			if requeue {
+				*settled = "requeue"
+			} else {
+				*settled = "drop"
+			}
+			return nil
+		},
+	}
+}
+
+func TestHandleFuzzyQueryMessage(t *testing.T) {
+	store := dbm.NewMemoryStore(6)
+	store.PutHotItems("weibo", dbm.HotItem{Title: "golang 发布"})
+	deps := ConsumerDeps{Fuzzy: store, Cache: store, Events: store}
+	query := dbm.FuzzyQuery{Keyword: "golang"}.Normalize()
+
+	var settled string
+	handleFuzzyQueryMessage(context.Background(), context.Background(), deps, recordDelivery(t, query, &settled))
+	if settled != "ack" {
+		t.Fatalf("settled = %q, want ack", settled)
+	}
+	cached, _ := store.GetHashConsistent(context.Background(), GetFuzzyCacheKey(query))
+	if cached["status"] != "ready" {
+		t.Fatalf("cache = %v, want ready", cached)
+	}
+
+	// 退出时（ctx 已取消）检索被中断，消息重新入队且不进入重试队列
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	other := dbm.FuzzyQuery{Keyword: "rust"}.Normalize()
+	handleFuzzyQueryMessage(ctx, context.WithoutCancel(ctx), deps, recordDelivery(t, other, &settled))
+	if settled != "requeue" {
+		t.Fatalf("settled = %q, want requeue on shutdown", settled)
+	}
+}
@@ -0,0 +1,91 @@
+package until
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+)
+
+// 模糊查询重试/死信相关消息头
+const (
+	retryCountHeader = "x-retry-count" // 已失败次数
+	lastErrorHeader  = "x-last-error"  // 最近一次失败原因
+)
+
+// retryFuzzyQuery 查询失败：未达到 fuzzy_retry.max_attempts 时投递到延迟重试队列（TTL 到期后经 DLX 回到主队列），
+// 否则进入死信队列；处理完成后确认原消息
+func retryFuzzyQuery(ctx context.Context, deps ConsumerDeps, msg Delivery, query dbm.FuzzyQuery, cause error) {
+	keyword := query.Keyword
+	attempts := retryCount(msg.Headers) + 1
+	maxAttempts := config.GetGlobalConfig().FuzzyRetry.MaxAttempts
+	if attempts >= maxAttempts {
+		deadLetterFuzzyQuery(ctx, deps, msg, query, cause)
+		return
+	}
+
+	slog.Warn("模糊查询失败，延迟重试", "keyword", keyword, "attempt", attempts, "max_attempts", maxAttempts, "error", cause)
+	if err := republish(ctx, FuzzyRetryQueueName, msg, attempts, cause); err != nil {
+		// 重试消息无法发布（outbox 也不可用）：退回原队列，避免丢失
+		slog.Error("发布模糊查询重试消息失败，消息退回原队列", "keyword", keyword, "error", err)
+		_ = msg.Nack(true)
+		return
+	}
+	_ = msg.Ack()
+}
+
+// deadLetterFuzzyQuery 消息进入死信队列，并将缓存状态标记为 failed（轮询接口据此返回失败原因）
+func deadLetterFuzzyQuery(ctx context.Context, deps ConsumerDeps, msg Delivery, query dbm.FuzzyQuery, cause error) {
+	keyword := query.Keyword
+	slog.Error("模糊查询最终失败，进入死信队列", "keyword", keyword, "attempts", retryCount(msg.Headers)+1, "error", cause)
+	if err := republish(ctx, FuzzyDeadLetterQueueName, msg, retryCount(msg.Headers)+1, cause); err != nil {
+		slog.Error("发布模糊查询死信消息失败", "keyword", keyword, "error", err)
+	}
+	if keyword != "" {
+		if err := deps.Cache.SetHash(ctx, GetFuzzyCacheKey(query), map[string]string{
+			"status":      "failed",
+			"error_msg":   cause.Error(),
+			"update_time": time.Now().Format("2006-01-02 15:04:05"),
+		}, FuzzyCacheExpire); err != nil {
+			slog.Error("写入模糊查询失败状态失败", "keyword", keyword, "error", err)
+		}
+		publishFuzzyEvent(ctx, deps, GetFuzzyCacheKey(query), FuzzyEvent{Status: "failed", Error: cause.Error()})
+	}
+	_ = msg.Ack()
+}
+
+// republish 复制原消息（保留优先级和消息头）并记录失败次数和原因后发布到指定队列
+func republish(ctx context.Context, queueName string, msg Delivery, attempts int, cause error) error {
+	headers := make(map[string]any, len(msg.Headers)+2)
+	maps.Copy(headers, msg.Headers)
+	headers[retryCountHeader] = int32(attempts)
+	headers[lastErrorHeader] = cause.Error()
+
+	publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return broker.Publish(publishCtx, queueName, Message{
+		Body:      msg.Body,
+		Priority:  msg.Priority,
+		Headers:   headers,
+		Timestamp: time.Now(),
+	})
+}
+
+// retryCount 读取消息头中的失败次数（经 outbox 或 Redis Streams 传递的消息头数值为 float64）
+func retryCount(headers map[string]any) int {
+	switch v := headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,227 @@
+package until
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+)
+
+// Notification 一次订阅提醒：某数据源一个批次中命中订阅的条目
+type Notification struct {
+	Subscription dbm.Subscription
+	Source       string
+	CrawledAt    time.Time
+	Items        []dbm.HotItem
+}
+
+// Notifier 通知渠道（发送失败返回 error，由调用方决定是否重试）
+type Notifier interface {
+	Notify(ctx context.Context, address string, n Notification) error
+}
+
+// Mailer 发送单封纯文本邮件（订阅提醒和邮箱验证共用）
+type Mailer interface {
+	SendMail(ctx context.Context, to, subject, body string) error
+}
+
+// NewNotifiers 按配置创建各渠道的通知器（未配置 email_list 时不支持邮件提醒）
+func NewNotifiers(cfg config.GlobalConfig) map[string]Notifier {
+	notifiers := map[string]Notifier{
+		dbm.ChannelWebhook: NewWebhookNotifier(NewWebhookClient(cfg.Notify.SendTimeout.Duration)),
+	}
+	if len(cfg.EmailList) > 0 {
+		notifiers[dbm.ChannelEmail] = NewSMTPNotifier(cfg.EmailList)
+	}
+	return notifiers
+}
+
+// SMTPNotifier 通过 email_list 中的账号轮流发信，某个账号失败时换下一个账号重试
+type SMTPNotifier struct {
+	accounts []config.EmailAccount
+	next     atomic.Uint64
+}
+
+func NewSMTPNotifier(accounts []config.EmailAccount) *SMTPNotifier {
+	return &SMTPNotifier{accounts: accounts}
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, address string, n Notification) error {
+	subject, body := renderEmail(n)
+	return s.SendMail(ctx, address, subject, body)
+}
+
+// SendMail 从下一个账号开始依次尝试发送，全部失败时返回各账号的错误
+func (s *SMTPNotifier) SendMail(ctx context.Context, to, subject, body string) error {
+	if len(s.accounts) == 0 {
+		return errors.New("no smtp account configured")
+	}
+	start := s.next.Add(1) - 1
+	var errs []error
+	for i := range s.accounts {
+		account := s.accounts[(start+uint64(i))%uint64(len(s.accounts))]
+		err := sendMail(ctx, account, to, buildMessage(account.Email, to, subject, body))
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", account.Email, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendMail 发送一封邮件：465 端口使用 SSL 直连，其他端口在服务器支持时升级 STARTTLS
+// 明文连接只允许向本机服务器认证（net/smtp 的限制，便于本地测试）
+func sendMail(ctx context.Context, account config.EmailAccount, to string, msg []byte) error {
+	host := account.Host()
+	addr := net.JoinHostPort(host, strconv.Itoa(account.Port()))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	if account.Port() == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && account.Port() != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(smtp.PlainAuth("", account.Email, account.AuthCode, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(account.Email); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage 组装 UTF-8 纯文本邮件（正文 base64 编码）
+func buildMessage(from, to, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+// renderEmail 邮件标题和正文
+func renderEmail(n Notification) (subject, body string) {
+	topic := n.Source
+	if len(n.Subscription.Keywords) > 0 {
+		topic = strings.Join(n.Subscription.Keywords, "、")
+	}
+	subject = fmt.Sprintf("热榜提醒：%s 有 %d 条新上榜内容", topic, len(n.Items))
+	var b strings.Builder
+	fmt.Fprintf(&b, "数据源 %s（爬取时间 %s）中有 %d 条内容命中你的订阅：\n\n", n.Source, n.CrawledAt.Format("2006-01-02 15:04"), len(n.Items))
+	for i, item := range n.Items {
+		fmt.Fprintf(&b, "%d. %s（第 %d 名", i+1, item.Title, item.Rank)
+		if item.HotText != "" {
+			fmt.Fprintf(&b, "，热度 %s", item.HotText)
+		} else if item.HotValue > 0 {
+			fmt.Fprintf(&b, "，热度 %.0f", item.HotValue)
+		}
+		b.WriteString("）\n")
+		if item.URL != "" {
+			fmt.Fprintf(&b, "   %s\n", item.URL)
+		}
+	}
+	return subject, b.String()
+}
+
+// WebhookNotifier 以 JSON POST 到订阅配置的地址，非 2xx 响应视为失败
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{client: client}
+}
+
+// webhookPayload 回调请求体
+type webhookPayload struct {
+	SubscriptionID string        `json:"subscription_id"`
+	Keywords       []string      `json:"keywords"`
+	Source         string        `json:"source"`
+	CrawledAt      time.Time     `json:"crawled_at"`
+	Items          []dbm.HotItem `json:"items"`
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, address string, n Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		SubscriptionID: n.Subscription.ID,
+		Keywords:       n.Subscription.Keywords,
+		Source:         n.Source,
+		CrawledAt:      n.CrawledAt,
+		Items:          n.Items,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package until
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+)
+
+// fakeSMTP 最小 SMTP 服务器：不声明 STARTTLS/AUTH，记录收到的收件人；reject 为 true 时拒绝 MAIL FROM
+type fakeSMTP struct {
+	ln     net.Listener
+	reject bool
+
+	mu   sync.Mutex
+	rcpt []string
+}
+
+func newFakeSMTP(t *testing.T, reject bool) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, reject: reject}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	var rcpt string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			if s.reject {
+				_ = tp.PrintfLine("550 mailbox unavailable")
+				continue
+			}
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			rcpt = strings.Trim(strings.TrimPrefix(line[len(cmd):], " TO:"), "<>")
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			if _, err := tp.ReadDotBytes(); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, rcpt)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTP) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.rcpt...)
+}
+
+func (s *fakeSMTP) account(email string) config.EmailAccount {
+	return config.EmailAccount{Email: email, SMTPHost: "127.0.0.1", SMTPPort: s.ln.Addr().(*net.TCPAddr).Port}
+}
+
+func TestSMTPNotifierRotatesAccounts(t *testing.T) {
+	a, b := newFakeSMTP(t, false), newFakeSMTP(t, false)
+	notifier := NewSMTPNotifier([]config.EmailAccount{a.account("a@example.com"), b.account("b@example.com")})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, to := range []string{"u1@example.com", "u2@example.com", "u3@example.com"} {
+		if err := notifier.SendMail(ctx, to, "主题", "正文"); err != nil {
+			t.Fatalf("send to %s: %v", to, err)
+		}
+	}
+	// 按 email_list 顺序轮流使用账号
+	if got := a.received(); len(got) != 2 || got[0] != "u1@example.com" || got[1] != "u3@example.com" {
+		t.Fatalf("account a received %v, want [u1 u3]", got)
+	}
+	if got := b.received(); len(got) != 1 || got[0] != "u2@example.com" {
+		t.Fatalf("account b received %v, want [u2]", got)
+	}
+}
+
+func TestSMTPNotifierFailsOverToNextAccount(t *testing.T) {
+	bad, good := newFakeSMTP(t, true), newFakeSMTP(t, false)
+	notifier := NewSMTPNotifier([]config.EmailAccount{bad.account("bad@example.com"), good.account("good@example.com")})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// 第一次从 bad 开始，失败后换 good；第二次直接轮到 good
+	for _, to := range []string{"u1@example.com", "u2@example.com"} {
+		if err := notifier.SendMail(ctx, to, "主题", "正文"); err != nil {
+			t.Fatalf("send to %s: %v", to, err)
+		}
+	}
+	if got := good.received(); len(got) != 2 {
+		t.Fatalf("good account received %v, want both mails", got)
+	}
+	if got := bad.received(); len(got) != 0 {
+		t.Fatalf("rejecting account received %v", got)
+	}
+
+	// 全部账号失败时返回错误
+	onlyBad := NewSMTPNotifier([]config.EmailAccount{bad.account("bad@example.com")})
+	if err := onlyBad.SendMail(ctx, "u3@example.com", "主题", "正文"); err == nil || !strings.Contains(err.Error(), "bad@example.com") {
+		t.Fatalf("err = %v, want failure naming the account", err)
+	}
+}
+
+func TestBuildMessageEncodesBody(t *testing.T) {
+	msg := buildMessage("from@example.com", "to@example.com", "热榜提醒", strings.Repeat("内容", 40))
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(msg))))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Content-Transfer-Encoding") != "base64" || !strings.HasPrefix(header.Get("Subject"), "=?UTF-8?b?") {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(msg), "\r\n"), "\r\n") {
+		if len(line) > 78 {
+			t.Fatalf("line too long: %d", len(line))
+		}
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestWebhookClientBlocksPrivateTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := CheckWebhookURL(ctx, srv.URL); err == nil {
+		t.Fatal("loopback webhook should be rejected at creation")
+	}
+	if _, err := CheckWebhookURL(ctx, "ftp://8.8.8.8/hook"); err == nil {
+		t.Fatal("non-http webhook should be rejected")
+	}
+	// 连接前检查解析后的地址（覆盖重定向和 DNS 重绑定）
+	err := NewWebhookNotifier(NewWebhookClient(time.Second)).Notify(ctx, srv.URL, Notification{})
+	if err == nil || !strings.Contains(err.Error(), errWebhookAddrBlocked.Error()) {
+		t.Fatalf("err = %v, want blocked address", err)
+	}
+	// 普通客户端可以访问，说明失败来自地址检查
+	if err := NewWebhookNotifier(srv.Client()).Notify(ctx, srv.URL, Notification{}); err != nil {
+		t.Fatalf("plain client: %v", err)
+	}
+}
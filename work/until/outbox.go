@@ -0,0 +1,259 @@
+package until
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	outboxFileExt = ".msg"
+	outboxDeadDir = "dead" // 无法投递的消息（被退回或超过重试次数），不再自动重试
+)
+
+var errOutboxFull = errors.New("mq outbox is full")
+
+// outboxMessage 暂存在磁盘上的待发布消息
+type outboxMessage struct {
+	Queue       string        `json:"queue"`
+	Headers     amqp091.Table `json:"headers,omitempty"`
+	Body        []byte        `json:"body"`
+	ContentType string        `json:"content_type"`
+	Priority    uint8         `json:"priority"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Attempts    int           `json:"attempts,omitempty"` // 已连接 broker 时的失败次数
+}
+
+func newOutboxMessage(queueName string, msg amqp091.Publishing) outboxMessage {
+	return outboxMessage{
+		Queue:       queueName,
+		Headers:     msg.Headers,
+		Body:        msg.Body,
+		ContentType: msg.ContentType,
+		Priority:    msg.Priority,
+		Timestamp:   msg.Timestamp,
+	}
+}
+
+func (o outboxMessage) publishing() amqp091.Publishing {
+	return amqp091.Publishing{
+		Headers:      o.Headers,
+		DeliveryMode: amqp091.Persistent,
+		ContentType:  o.ContentType,
+		Body:         o.Body,
+		Timestamp:    o.Timestamp,
+		Priority:     o.Priority,
+	}
+}
+
+// outbox 本地磁盘 outbox：每条消息一个文件，文件名为递增序号，按序号顺序重试
+// 进程重启后从目录恢复，继续投递上次未确认的消息
+type outbox struct {
+	dir         string
+	max         int // 最多暂存的消息数
+	maxAttempts int // 单条消息最多重试次数，超出后移入死信目录
+
+	mu      sync.Mutex
+	seq     uint64
+	pending int
+	wake    chan struct{}
+}
+
+// openOutbox 打开（必要时创建）outbox 目录并恢复序号和积压数量
+// 序号同时参考死信目录，避免新消息移入死信目录时覆盖同名文件
+func openOutbox(dir string, max, maxAttempts int) (*outbox, error) {
+	if err := os.MkdirAll(filepath.Join(dir, outboxDeadDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create outbox dir failed: %w", err)
+	}
+	names, err := outboxFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	dead, err := outboxFiles(filepath.Join(dir, outboxDeadDir))
+	if err != nil {
+		return nil, err
+	}
+	o := &outbox{dir: dir, max: max, maxAttempts: maxAttempts, pending: len(names), wake: make(chan struct{}, 1)}
+	for _, files := range [][]string{names, dead} {
+		if len(files) > 0 {
+			if seq, _ := strconv.ParseUint(strings.TrimSuffix(files[len(files)-1], outboxFileExt), 10, 64); seq > o.seq {
+				o.seq = seq
+			}
+		}
+	}
+	if len(names) > 0 {
+		slog.Warn("outbox 中有上次未投递的消息", "dir", dir, "pending", len(names))
+	}
+	if len(dead) > 0 {
+		slog.Warn("outbox 死信目录中有无法投递的消息", "dir", filepath.Join(dir, outboxDeadDir), "count", len(dead))
+	}
+	return o, nil
+}
+
+// outboxFiles 按序号升序返回消息文件名
+func outboxFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read outbox dir failed: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), outboxFileExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names) // 定长序号，字典序即数值序
+	return names, nil
+}
+
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pending
+}
+
+// enqueue 追加一条消息
+func (o *outbox) enqueue(msg outboxMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending >= o.max {
+		return errOutboxFull
+	}
+	o.seq++
+	name := filepath.Join(o.dir, fmt.Sprintf("%020d%s", o.seq, outboxFileExt))
+	if err := o.write(name, msg); err != nil {
+		return err
+	}
+	o.pending++
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// flush 按序发布积压消息，遇到失败立即停止（保证顺序），返回成功投递的条数
+// 被退回或已连接 broker 时累计失败 maxAttempts 次的消息移入死信目录，避免单条消息永久阻塞 outbox
+func (o *outbox) flush(ctx context.Context, send func(ctx context.Context, msg outboxMessage) error) (int, error) {
+	names, err := outboxFiles(o.dir)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		path := filepath.Join(o.dir, name)
+		var msg outboxMessage
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &msg)
+		}
+		if err != nil {
+			// 损坏的消息无法重试，移除避免阻塞后续消息
+			slog.Error("丢弃无法解析的 outbox 消息", "file", path, "error", err)
+			publishMetrics.Add("outbox_dropped", 1)
+			err = os.Remove(path)
+		} else if sendErr := send(ctx, msg); sendErr == nil {
+			delivered++
+			err = os.Remove(path)
+		} else if o.retryable(&msg, sendErr) {
+			if err := o.write(path, msg); err != nil {
+				slog.Warn("更新 outbox 消息重试次数失败", "file", path, "error", err)
+			}
+			return delivered, sendErr
+		} else {
+			slog.Error("outbox 消息无法投递，移入死信目录", "file", path, "queue", msg.Queue, "attempts", msg.Attempts, "error", sendErr)
+			publishMetrics.Add("outbox_dropped", 1)
+			err = o.deadLetter(path)
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("remove outbox message failed: %w", err)
+		}
+		o.mu.Lock()
+		o.pending--
+		o.mu.Unlock()
+	}
+	return delivered, nil
+}
+
+// retryable 判断发布失败的消息是否继续留在 outbox 重试
+// 未连接 broker 时不计入重试次数（等待重连），被退回的消息（队列不存在）重试也不会成功
+func (o *outbox) retryable(msg *outboxMessage, err error) bool {
+	if errors.Is(err, errPublishReturned) {
+		return false
+	}
+	if errors.Is(err, errMQNotConnected) || errors.Is(err, errMQClosed) {
+		return true
+	}
+	msg.Attempts++
+	return msg.Attempts < o.maxAttempts
+}
+
+// write 写入消息文件（先写临时文件再重命名，避免重启后读到半条消息）
+func (o *outbox) write(path string, msg outboxMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write outbox message failed: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("commit outbox message failed: %w", err)
+	}
+	return nil
+}
+
+// deadLetter 将消息文件移入死信目录（保留供人工排查或重新投递，不再自动重试）
+func (o *outbox) deadLetter(path string) error {
+	return os.Rename(path, filepath.Join(o.dir, outboxDeadDir, filepath.Base(path)))
+}
+
+// relay 定期（或有新消息时）重试 outbox 中的消息，直到 done 关闭
+func (m *mqClient) relay(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if m.outbox.len() > 0 {
+			delivered, err := m.outbox.flush(ctx, func(ctx context.Context, msg outboxMessage) error {
+				return m.publishConfirmed(ctx, msg.Queue, msg.publishing())
+			})
+			publishMetrics.Add("outbox_delivered", int64(delivered))
+			if err != nil && ctx.Err() == nil {
+				slog.Warn("outbox 重试发布失败，稍后继续", "pending", m.outbox.len(), "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.outbox.wake:
+		}
+	}
+}
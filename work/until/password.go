@@ -0,0 +1,19 @@
+package until
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword 生成密码的 bcrypt 哈希（bcrypt 只处理前 72 字节，注册时需限制长度）
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验明文密码与 bcrypt 哈希是否匹配
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
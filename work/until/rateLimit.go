@@ -0,0 +1,74 @@
+package until
+
+import (
+	"github/AHKLIC/Web/work/config"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenBucket 单个客户端的令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// ipRateLimiter 按客户端 IP 限流（参数每次从配置读取，支持热更新）
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+const bucketIdleTTL = 10 * time.Minute // 空闲桶清理时间
+
+// allow 按当前速率补充令牌并尝试消耗一个
+func (l *ipRateLimiter) allow(key string, rps float64, burst int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 定期清理长时间未访问的桶，避免内存增长
+	if now.Sub(l.lastSweep) > bucketIdleTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > bucketIdleTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(burst), b.tokens+elapsed*rps)
+	b.lastSeen = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitMiddleware 按客户端 IP 的令牌桶限流中间件（配置 rate_limit，可热更新）
+// 客户端 IP 取自 c.ClientIP()，仅在请求来自 trusted_proxies 时采用 X-Forwarded-For
+func RateLimitMiddleware() gin.HandlerFunc {
+	limiter := &ipRateLimiter{buckets: make(map[string]*tokenBucket)}
+	return func(c *gin.Context) {
+		cfg := config.GetGlobalConfig().RateLimit
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+		if !limiter.allow(c.ClientIP(), cfg.RequestsPerSecond, cfg.Burst, time.Now()) {
+			c.Error(&BusinessError{Code: 429, Message: "请求过于频繁，请稍后再试"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,159 @@
+package until
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色
+const (
+	RoleAdmin = "admin" // 管理员
+	RoleUser  = "user"  // 普通注册用户
+)
+
+// 权限
+const (
+	PermProfileRead = "profile:read"        // 查看个人信息
+	PermDataOperate = "data:operate"        // 新增/修改业务数据
+	PermDataDelete  = "data:delete"         // 删除业务数据
+	PermAdminData   = "data:admin"          // 操作管理员数据
+	PermUserManage  = "user:manage"         // 管理用户角色
+	PermSubscribe   = "subscription:manage" // 管理自己的订阅提醒
+)
+
+// rolePermissions 角色默认拥有的权限
+var rolePermissions = map[string][]string{
+	RoleAdmin: {PermProfileRead, PermDataOperate, PermDataDelete, PermAdminData, PermUserManage, PermSubscribe},
+	RoleUser:  {PermProfileRead, PermDataOperate, PermSubscribe},
+}
+
+// DefaultRoles 新注册用户的默认角色
+func DefaultRoles() []string {
+	return []string{RoleUser}
+}
+
+// IsBootstrapAdmin 用户名是否在配置的 admin_users 中
+func IsBootstrapAdmin(username string) bool {
+	return slices.Contains(config.GetGlobalConfig().AdminUsers, username)
+}
+
+// BootstrapAdmins 启动时为 admin_users 中已注册的账号授予 admin 角色（用于新部署创建首个管理员）
+// 只处理启动时已存在的账号：未注册的用户名跳过，避免他人抢先注册该用户名获得管理员权限
+func BootstrapAdmins(ctx context.Context, users dbm.UserStore) error {
+	for _, username := range config.GetGlobalConfig().AdminUsers {
+		user, err := users.GetUserByUsername(ctx, username)
+		if errors.Is(err, dbm.ErrUserNotFound) {
+			slog.Warn("admin_users 中的用户尚未注册，跳过（注册后重启服务生效）", "username", username)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get bootstrap admin %s failed: %w", username, err)
+		}
+		roles := EffectiveRoles(user.Roles)
+		if slices.Contains(roles, RoleAdmin) {
+			continue
+		}
+		if _, err := users.UpdateUserRoles(ctx, user.UserID, append(slices.Clone(roles), RoleAdmin), user.Permissions); err != nil {
+			return fmt.Errorf("grant bootstrap admin %s failed: %w", username, err)
+		}
+		slog.Info("按 admin_users 授予管理员角色", "userId", user.UserID, "username", username)
+	}
+	return nil
+}
+
+// IsKnownRole 判断角色是否已定义
+func IsKnownRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsKnownPermission 判断权限是否已定义
+func IsKnownPermission(perm string) bool {
+	for _, perms := range rolePermissions {
+		if slices.Contains(perms, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveRoles 用户生效的角色（历史用户未设置角色时按普通用户处理）
+func EffectiveRoles(roles []string) []string {
+	if len(roles) == 0 {
+		return DefaultRoles()
+	}
+	return roles
+}
+
+// EffectivePermissions 合并角色默认权限和用户额外权限（去重）
+func EffectivePermissions(roles, extra []string) []string {
+	perms := make([]string, 0, len(extra))
+	for _, role := range EffectiveRoles(roles) {
+		for _, perm := range rolePermissions[role] {
+			if !slices.Contains(perms, perm) {
+				perms = append(perms, perm)
+			}
+		}
+	}
+	for _, perm := range extra {
+		if !slices.Contains(perms, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// HasRole 判断当前请求用户是否拥有任一指定角色
+func HasRole(c *gin.Context, roles ...string) bool {
+	owned := c.GetStringSlice("roles")
+	for _, role := range roles {
+		if slices.Contains(owned, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission 判断当前请求用户是否拥有全部指定权限
+func HasPermission(c *gin.Context, perms ...string) bool {
+	owned := c.GetStringSlice("permissions")
+	for _, perm := range perms {
+		if !slices.Contains(owned, perm) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireRole 角色校验中间件（拥有任一角色即可通过），需放在 JWTMiddleware 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, roles...) {
+			c.Error(&BusinessError{Code: 403, Message: "权限不足：需要角色 " + strings.Join(roles, "/")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 权限校验中间件（需拥有全部权限），需放在 JWTMiddleware 之后
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, perms...) {
+			c.Error(&BusinessError{Code: 403, Message: "权限不足：需要权限 " + strings.Join(perms, ",")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
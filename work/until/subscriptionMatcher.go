@@ -0,0 +1,168 @@
+package until
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+)
+
+const (
+	notifyBatchPrefix = "notify:batch:" // 已处理的批次（多实例部署时只由一个实例处理）
+	notifySentPrefix  = "notify:sent:"  // 已提醒的条目（订阅 + 标题）
+	notifyRatePrefix  = "notify:rate:"  // 订阅的提醒冷却期
+	maxNotifyItems    = 20              // 单次提醒最多列出的条目数
+)
+
+// MatcherDeps 订阅匹配依赖
+type MatcherDeps struct {
+	HotData   dbm.HotDataStore
+	Subs      dbm.SubscriptionStore
+	Locker    dbm.Locker          // 批次认领、条目去重和限流（SET NX + 过期时间）
+	Notifiers map[string]Notifier // 渠道 → 通知器
+}
+
+// RunSubscriptionMatcher 定期检查各数据源的最新批次，将命中订阅的条目通过订阅配置的渠道提醒，ctx 取消后退出
+func RunSubscriptionMatcher(ctx context.Context, deps MatcherDeps) {
+	interval := config.GetGlobalConfig().Notify.CheckInterval.Duration
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	seen := make(map[string]time.Time) // source → 本实例已检查过的最新批次时间
+	for {
+		if err := MatchNewBatches(ctx, deps, seen); err != nil && ctx.Err() == nil {
+			slog.Error("订阅匹配失败", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MatchNewBatches 检查一次各数据源的最新批次（seen 记录已检查的批次，跳过未更新的数据源）
+func MatchNewBatches(ctx context.Context, deps MatcherDeps, seen map[string]time.Time) error {
+	cfg := config.GetGlobalConfig().Notify
+	batches, err := deps.HotData.GetLatestDataBySources(ctx, config.GetGlobalConfig().SourceList)
+	if err != nil {
+		return fmt.Errorf("get latest batches failed: %w", err)
+	}
+	var fresh []*dbm.HotBatch
+	for source, batch := range batches {
+		if batch.CrawledAt.After(seen[source]) {
+			fresh = append(fresh, batch)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	subs, err := deps.Subs.ListAllSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("list subscriptions failed: %w", err)
+	}
+	token := GenerateReqID()
+	for _, batch := range fresh {
+		seen[batch.Source] = batch.CrawledAt
+		batchKey := fmt.Sprintf("%s%s:%d", notifyBatchPrefix, batch.Source, batch.CrawledAt.UnixMilli())
+		claimed, err := deps.Locker.TryLock(ctx, batchKey, token, cfg.DedupeTTL.Duration)
+		if err != nil {
+			delete(seen, batch.Source) // 下次重试
+			slog.Warn("认领批次失败", "source", batch.Source, "error", err)
+			continue
+		}
+		if !claimed {
+			continue // 其他实例已处理
+		}
+		for _, sub := range subs {
+			notifySubscription(ctx, deps, cfg, token, sub, batch)
+		}
+	}
+	return nil
+}
+
+// notifySubscription 提醒单个订阅：认领未提醒过的条目 → 检查冷却期 → 逐个渠道发送
+// 未发出（冷却期内或全部渠道失败）的条目释放认领，下次出现在榜单中时再提醒
+func notifySubscription(ctx context.Context, deps MatcherDeps, cfg config.NotifyConfig, token string, sub dbm.Subscription, batch *dbm.HotBatch) {
+	var items []dbm.HotItem
+	var claimedKeys []string
+	for _, item := range batch.Items {
+		if item.Source == "" {
+			item.Source = batch.Source
+		}
+		if !sub.Matches(item) {
+			continue
+		}
+		key := notifySentKey(sub.ID, item.Title)
+		ok, err := deps.Locker.TryLock(ctx, key, token, cfg.DedupeTTL.Duration)
+		if err != nil {
+			slog.Warn("订阅去重失败", "subscription", sub.ID, "error", err)
+			continue
+		}
+		if !ok {
+			notifyMetrics.Add("deduped", 1)
+			continue
+		}
+		claimedKeys = append(claimedKeys, key)
+		if len(items) < maxNotifyItems {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	release := func(keys ...string) {
+		for _, key := range keys {
+			_ = deps.Locker.Unlock(ctx, key, token)
+		}
+	}
+	// 超出单次上限的条目不算已提醒
+	if len(claimedKeys) > len(items) {
+		release(claimedKeys[len(items):]...)
+		claimedKeys = claimedKeys[:len(items)]
+	}
+
+	rateKey := notifyRatePrefix + sub.ID
+	if cfg.MinInterval.Duration > 0 {
+		ok, err := deps.Locker.TryLock(ctx, rateKey, token, cfg.MinInterval.Duration)
+		if err != nil || !ok {
+			notifyMetrics.Add("rate_limited", 1)
+			release(claimedKeys...)
+			return
+		}
+	}
+
+	n := Notification{Subscription: sub, Source: batch.Source, CrawledAt: batch.CrawledAt, Items: items}
+	var delivered int
+	for _, target := range sub.Targets() {
+		notifier, ok := deps.Notifiers[target.Channel]
+		if !ok {
+			slog.Warn("通知渠道不可用", "subscription", sub.ID, "channel", target.Channel)
+			continue
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, cfg.SendTimeout.Duration)
+		err := notifier.Notify(sendCtx, target.Address, n)
+		cancel()
+		if err != nil {
+			notifyMetrics.Add(target.Channel+"_failed", 1)
+			slog.Error("发送订阅提醒失败", "subscription", sub.ID, "channel", target.Channel, "error", err)
+			continue
+		}
+		notifyMetrics.Add(target.Channel+"_sent", 1)
+		delivered++
+	}
+	if delivered == 0 {
+		release(append(claimedKeys, rateKey)...)
+	}
+}
+
+// notifySentKey 订阅 + 规范化标题的去重键
+func notifySentKey(subID, title string) string {
+	sum := md5.Sum([]byte(strings.ToLower(strings.Join(strings.Fields(title), " "))))
+	return notifySentPrefix + subID + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,230 @@
+package until
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
+)
+
+const testMinInterval = 200 * time.Millisecond
+
+const testConfig = `{
+  "mongo_url": "mongodb://localhost:27017",
+  "mongodb_name_data": "crawlersData",
+  "mongodb_name_users": "userData",
+  "redis_mode": "standalone",
+  "redis_addrs": ["localhost:6379"],
+  "mq_backend": "memory",
+  "source_list": ["weibo", "zhihu"],
+  "log_level": "error",
+  "notify": {"check_interval": "1m", "dedupe_ttl": "24h", "min_interval": "200ms", "send_timeout": "2s", "max_subscriptions": 20},
+  "jwt": {"allow_ephemeral": true}
+}`
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "until-test")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		panic(err)
+	}
+	if err := config.Init(path); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// recordingNotifier 记录每次提醒的条目标题，fail 不为空时按顺序返回其中的错误
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent [][]string
+	fail []error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, address string, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.fail) > 0 {
+		err := r.fail[0]
+		r.fail = r.fail[1:]
+		if err != nil {
+			return err
+		}
+	}
+	titles := make([]string, 0, len(n.Items))
+	for _, item := range n.Items {
+		titles = append(titles, item.Title)
+	}
+	r.sent = append(r.sent, titles)
+	return nil
+}
+
+func (r *recordingNotifier) deliveries() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]string(nil), r.sent...)
+}
+
+// matcherFixture 内存存储 + 一个 webhook 订阅
+type matcherFixture struct {
+	store    *dbm.MemoryStore
+	notifier *recordingNotifier
+	deps     MatcherDeps
+	seen     map[string]time.Time
+	sub      dbm.Subscription
+	crawled  time.Time
+}
+
+func newMatcherFixture(t *testing.T) *matcherFixture {
+	t.Helper()
+	store := dbm.NewMemoryStore(10)
+	notifier := &recordingNotifier{}
+	sub := dbm.Subscription{ID: dbm.NewSubscriptionID(), UserID: 1, Keywords: []string{"golang"}, Sources: []string{}, Webhook: "https://example.com/hook"}
+	if err := store.CreateSubscription(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	return &matcherFixture{
+		store:    store,
+		notifier: notifier,
+		deps: MatcherDeps{
+			HotData:   store,
+			Subs:      store,
+			Locker:    store,
+			Notifiers: map[string]Notifier{dbm.ChannelWebhook: notifier},
+		},
+		seen:    make(map[string]time.Time),
+		sub:     sub,
+		crawled: time.Now().Add(-time.Hour),
+	}
+}
+
+// publish 写入 weibo 的一个新批次并运行一次匹配
+func (f *matcherFixture) publish(t *testing.T, titles ...string) {
+	t.Helper()
+	f.crawled = f.crawled.Add(time.Minute)
+	batch := dbm.HotBatch{Source: "weibo", CrawledAt: f.crawled}
+	for i, title := range titles {
+		batch.Items = append(batch.Items, dbm.HotItem{Title: title, Rank: i + 1})
+	}
+	f.store.PutBatch(batch)
+	if err := MatchNewBatches(context.Background(), f.deps, f.seen); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// held 锁是否被占用（未占用时立即释放探测锁）
+func (f *matcherFixture) held(t *testing.T, key string) bool {
+	t.Helper()
+	ok, err := f.store.TryLock(context.Background(), key, "probe", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		_ = f.store.Unlock(context.Background(), key, "probe")
+	}
+	return !ok
+}
+
+func TestMatchNewBatchesDedupes(t *testing.T) {
+	f := newMatcherFixture(t)
+	f.publish(t, "Golang 1.30 发布", "无关新闻")
+	if got := f.notifier.deliveries(); len(got) != 1 || len(got[0]) != 1 || got[0][0] != "Golang 1.30 发布" {
+		t.Fatalf("deliveries = %v, want the matching item once", got)
+	}
+	if !f.held(t, notifySentKey(f.sub.ID, "Golang 1.30 发布")) {
+		t.Fatal("sent key should be held after delivery")
+	}
+
+	// 等冷却期结束，排除限流的影响：同一条目（标题空白、大小写不同）不再提醒
+	time.Sleep(testMinInterval + 50*time.Millisecond)
+	f.publish(t, "golang  1.30 发布")
+	if got := f.notifier.deliveries(); len(got) != 1 {
+		t.Fatalf("deliveries = %v, want duplicate suppressed", got)
+	}
+	// 去重后没有条目要发，不应占用冷却期
+	if f.held(t, notifyRatePrefix+f.sub.ID) {
+		t.Fatal("rate key should not be taken when everything is deduped")
+	}
+
+	// 同一批次不会被重复处理
+	if err := MatchNewBatches(context.Background(), f.deps, map[string]time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.notifier.deliveries(); len(got) != 1 {
+		t.Fatalf("deliveries = %v, want claimed batch skipped", got)
+	}
+}
+
+func TestMatchNewBatchesRateLimits(t *testing.T) {
+	f := newMatcherFixture(t)
+	f.publish(t, "golang 周报 1")
+	f.publish(t, "golang 周报 2")
+	if got := f.notifier.deliveries(); len(got) != 1 {
+		t.Fatalf("deliveries = %v, want second notification rate limited", got)
+	}
+	// 冷却期内命中的条目释放认领，冷却期后再次上榜时提醒
+	if f.held(t, notifySentKey(f.sub.ID, "golang 周报 2")) {
+		t.Fatal("rate-limited item should not be marked as sent")
+	}
+	time.Sleep(testMinInterval + 50*time.Millisecond)
+	f.publish(t, "golang 周报 1", "golang 周报 2")
+	got := f.notifier.deliveries()
+	if len(got) != 2 || len(got[1]) != 1 || got[1][0] != "golang 周报 2" {
+		t.Fatalf("deliveries = %v, want only the held-back item after cooldown", got)
+	}
+}
+
+func TestMatchNewBatchesReleasesClaimsOnFailure(t *testing.T) {
+	f := newMatcherFixture(t)
+	f.notifier.fail = []error{errors.New("connection refused")}
+	f.publish(t, "golang 故障复盘")
+	if got := f.notifier.deliveries(); len(got) != 0 {
+		t.Fatalf("deliveries = %v, want failed delivery", got)
+	}
+	if f.held(t, notifySentKey(f.sub.ID, "golang 故障复盘")) || f.held(t, notifyRatePrefix+f.sub.ID) {
+		t.Fatal("claims should be released after failed delivery")
+	}
+
+	// 失败后不占用冷却期，下一批次立即重发
+	f.publish(t, "golang 故障复盘")
+	if got := f.notifier.deliveries(); len(got) != 1 || got[0][0] != "golang 故障复盘" {
+		t.Fatalf("deliveries = %v, want resend on next batch", got)
+	}
+}
+
+func TestMatchNewBatchesSkipsUnverifiedEmail(t *testing.T) {
+	f := newMatcherFixture(t)
+	mail := &recordingNotifier{}
+	f.deps.Notifiers[dbm.ChannelEmail] = mail
+	sub := dbm.Subscription{ID: dbm.NewSubscriptionID(), UserID: 2, Keywords: []string{"rust"}, Sources: []string{}, Email: "u@example.com",
+		EmailCodeHash: HashEmailVerifyCode("ABCDE23456"), EmailCodeExp: time.Now().Add(time.Hour)}
+	if err := f.store.CreateSubscription(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	f.publish(t, "rust 2030 路线图")
+	if got := mail.deliveries(); len(got) != 0 {
+		t.Fatalf("unverified email received %v", got)
+	}
+
+	if err := f.store.VerifySubscriptionEmail(context.Background(), 2, sub.ID, HashEmailVerifyCode("wrong")); !errors.Is(err, dbm.ErrEmailCodeInvalid) {
+		t.Fatalf("wrong code err = %v", err)
+	}
+	if err := f.store.VerifySubscriptionEmail(context.Background(), 2, sub.ID, HashEmailVerifyCode(" abcde23456 ")); err != nil {
+		t.Fatal(err)
+	}
+	f.publish(t, "rust 2030 路线图")
+	if got := mail.deliveries(); len(got) != 1 {
+		t.Fatalf("verified email deliveries = %v, want one", got)
+	}
+}
@@ -3,11 +3,19 @@ package until
 import (
 	"context"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github/AHKLIC/Web/work/config"
+	"github/AHKLIC/Web/work/dbm"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,15 +25,26 @@ import (
 
 // 全局配置
 const (
-	JWTSecret     = "your-secret-key-32bytes-long-1234" // 生产环境用环境变量读取，至少32位
-	JWTExpireHour = 24 * 30                             // JWT 有效期（小时）
+	JWTIssuer = "AHKLIC-GO-WEB" // 签发者（令牌有效期见配置 jwt.access_token_ttl / jwt.refresh_token_ttl）
 )
 
+var errTokenRevoked = errors.New("token revoked")
+
+// TokenPair 登录/刷新返回的令牌对
+type TokenPair struct {
+	AccessToken      string `json:"token"`              // 访问令牌（JWT）
+	RefreshToken     string `json:"refresh_token"`      // 刷新令牌（一次性，使用后轮换）
+	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌有效期（秒）
+}
+
 // JWT 自定义声明（存储用户核心信息）
 type JwtClaims struct {
-	UserID               uint64 `json:"user_id"`
-	Username             string `json:"username"`
-	jwt.RegisteredClaims        // 内置标准声明（过期时间、签发时间等）
+	UserID               uint64   `json:"user_id"`
+	Username             string   `json:"username"`
+	Roles                []string `json:"roles,omitempty"` // 角色
+	Permissions          []string `json:"perms,omitempty"` // 生效权限（角色权限+额外权限）
+	jwt.RegisteredClaims          // 内置标准声明（过期时间、签发时间等）
 }
 
 // 统一错误响应结构体
@@ -66,21 +85,31 @@ func GenerateReqID() string {
 	return uuid.NewString()
 }
 
-// 生成关键词的 MD5 哈希（作为缓存键核心）
-func generateKeywordHash(keyword string) string {
-	hash := md5.New()
-	hash.Write([]byte(keyword))
-	return string(hash.Sum(nil))
+// 生成检索条件的 MD5 哈希（作为缓存键核心），条件需已规范化，含义相同的条件得到相同的键
+func generateQueryHash(query dbm.FuzzyQuery) string {
+	// 结果结构版本计入哈希，契约变更后不会读到旧结构的缓存
+	sum := md5.Sum(fmt.Appendf(nil, "v%d:%s", dbm.HotSchemaVersion, query.CanonicalKey()))
+	return hex.EncodeToString(sum[:])
 }
 
 // 生成模糊查询缓存键
-func GetFuzzyCacheKey(keyword string) string {
-	return fmt.Sprintf("%s%s", FuzzyCachePrefix, generateKeywordHash(keyword))
+func GetFuzzyCacheKey(query dbm.FuzzyQuery) string {
+	return fmt.Sprintf("%s%s", FuzzyCachePrefix, generateQueryHash(query))
+}
+
+// 生成模糊查询事件频道（按缓存键区分，同一关键词的请求共享进度）
+func GetFuzzyEventChannel(cacheKey string) string {
+	return FuzzyEventPrefix + strings.TrimPrefix(cacheKey, FuzzyCachePrefix)
+}
+
+// 生成批次对比缓存键（由数据源和两个批次的爬取时间确定）
+func GetBatchDiffCacheKey(source string, base, target time.Time) string {
+	return fmt.Sprintf("%sv%d:%s:%d:%d", BatchDiffPrefix, dbm.HotSchemaVersion, source, base.UnixMilli(), target.UnixMilli())
 }
 
 // 生成模糊查询分布式锁键
-func GetFuzzyLockKey(keyword string) string {
-	return fmt.Sprintf("%s%s", FuzzyLockPrefix, generateKeywordHash(keyword))
+func GetFuzzyLockKey(query dbm.FuzzyQuery) string {
+	return fmt.Sprintf("%s%s", FuzzyLockPrefix, generateQueryHash(query))
 }
 
 // 全局错误处理和日志输出中间件（捕获所有 panic 和错误）并输出日志
@@ -136,50 +165,183 @@ func ErrorAndLogHandler() gin.HandlerFunc {
 				layout.Status = 500
 			}
 			layout.Error = err.Err.Error()
-			go func() {
-				logCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-				defer cancel()
-				logData, _ := json.Marshal(layout)
-				if err := PublishMQ(logCtx, AccessLogQueueName, logData); err != nil {
-					slog.Error("publish access log msg failed: ", "error", err)
-				}
-			}()
+			publishAccessLog(layout)
 
 			c.Abort()
 		} else {
-			go func() {
-				logCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-				defer cancel()
-				layout.Status = c.Writer.Status()
-				logData, _ := json.Marshal(layout)
-				if err := PublishMQ(logCtx, AccessLogQueueName, logData); err != nil {
-					slog.Error("publish access log msg failed: ", "error", err)
-				}
-			}()
+			layout.Status = c.Writer.Status()
+			publishAccessLog(layout)
+		}
+	}
+}
+
+// accessLogWG 投递中的访问日志（退出时等待全部投递完成）
+var accessLogWG sync.WaitGroup
+
+// publishAccessLog 异步投递访问日志到 MQ
+func publishAccessLog(layout LogLayout) {
+	accessLogWG.Add(1)
+	go func() {
+		defer accessLogWG.Done()
+		logCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		logData, _ := json.Marshal(layout)
+		if err := PublishMQ(logCtx, AccessLogQueueName, logData); err != nil {
+			slog.Error("publish access log msg failed: ", "error", err)
 		}
+	}()
+}
+
+// FlushAccessLogs 等待已产生的访问日志投递完成（HTTP 服务停止后、关闭 MQ 前调用）
+func FlushAccessLogs(ctx context.Context) error {
+	return waitGroupWait(ctx, &accessLogWG)
+}
+
+// waitGroupWait 等待 wg 完成，ctx 到期时放弃等待
+func waitGroupWait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-// JWT 生成工具（登录成功后调用）
-func GenerateJWT(userID uint64, username string) (string, error) {
+// JWT 生成工具（登录成功后调用），每个令牌带唯一 jti 用于吊销
+func GenerateJWT(user *dbm.User) (string, error) {
+	now := time.Now()
 	// 构建 JWT 声明
 	claims := JwtClaims{
-		UserID:   userID,
-		Username: username,
+		UserID:      user.UserID,
+		Username:    user.Username,
+		Roles:       EffectiveRoles(user.Roles),
+		Permissions: EffectivePermissions(user.Roles, user.Permissions),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * JWTExpireHour)), // 过期时间
-			IssuedAt:  jwt.NewNumericDate(time.Now()),                                // 签发时间
-			Issuer:    "AHKLIC-GO-WEB",                                               // 签发者
+			ID:        uuid.NewString(),                                                                  // 令牌唯一标识（jti）
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.GetGlobalConfig().JWT.AccessTokenTTL.Duration)), // 过期时间
+			IssuedAt:  jwt.NewNumericDate(now),                                                           // 签发时间
+			Issuer:    JWTIssuer,                                                                         // 签发者
 		},
 	}
 
-	// 生成 Token（使用 HS256 算法）
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(JWTSecret))
+	// 使用当前生效的非对称密钥签名（头部携带 kid）
+	return signJWT(claims)
+}
+
+// IssueTokenPair 签发访问令牌和刷新令牌（刷新令牌保存在 tokens）
+func IssueTokenPair(ctx context.Context, tokens dbm.TokenStore, user *dbm.User) (*TokenPair, error) {
+	jwtCfg := config.GetGlobalConfig().JWT
+	accessToken, err := GenerateJWT(user)
+	if err != nil {
+		return nil, fmt.Errorf("generate access token failed: %w", err)
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token failed: %w", err)
+	}
+	owner := dbm.RefreshTokenOwner{UserID: user.UserID, Username: user.Username}
+	if err := tokens.SaveRefreshToken(ctx, hashRefreshToken(refreshToken), owner, jwtCfg.RefreshTokenTTL.Duration); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int64(jwtCfg.AccessTokenTTL.Seconds()),
+		RefreshExpiresIn: int64(jwtCfg.RefreshTokenTTL.Seconds()),
+	}, nil
 }
 
-// JWT 验证中间件（需要认证的路由添加此中间件）
-func JWTMiddleware() gin.HandlerFunc {
+// RefreshTokenPair 使用刷新令牌换取新的令牌对（旧刷新令牌立即失效）
+// 重新读取用户记录，使角色变更在下次刷新时生效
+func RefreshTokenPair(ctx context.Context, tokens dbm.TokenStore, users dbm.UserStore, refreshToken string) (*TokenPair, error) {
+	owner, err := tokens.ConsumeRefreshToken(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	user, err := users.GetUserByID(ctx, owner.UserID)
+	if err != nil {
+		if errors.Is(err, dbm.ErrUserNotFound) {
+			return nil, dbm.ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+	return IssueTokenPair(ctx, tokens, user)
+}
+
+// RevokeTokens 吊销访问令牌（按 jti）并删除刷新令牌（可选）
+func RevokeTokens(ctx context.Context, tokens dbm.TokenStore, jti string, expireAt time.Time, refreshToken string) error {
+	if err := tokens.RevokeToken(ctx, jti, time.Until(expireAt)); err != nil {
+		return fmt.Errorf("revoke access token failed: %w", err)
+	}
+	if refreshToken != "" {
+		if err := tokens.DeleteRefreshToken(ctx, hashRefreshToken(refreshToken)); err != nil {
+			return fmt.Errorf("delete refresh token failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// generateRefreshToken 生成随机刷新令牌（32 字节，URL 安全 base64）
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashRefreshToken 刷新令牌摘要（Redis 中只保存摘要）
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseJWT 校验签名、有效期，并检查 jti 是否已被吊销
+func parseJWT(ctx context.Context, tokens dbm.TokenStore, tokenStr string) (*JwtClaims, error) {
+	// 按 kid 选择验签公钥，轮换期间新旧密钥签发的令牌均可通过
+	token, err := jwt.ParseWithClaims(tokenStr, &JwtClaims{}, jwtKeyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(JWTIssuer),
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*JwtClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	revoked, err := tokens.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("check token revocation failed: %w", err)
+	}
+	if revoked {
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+// setClaimsContext 将 claims 中的用户信息存入上下文（后续路由可通过 c.Get 获取）
+func setClaimsContext(c *gin.Context, claims *JwtClaims) {
+	c.Set("userId", claims.UserID)
+	c.Set("userName", claims.Username)
+	c.Set("roles", claims.Roles)
+	c.Set("permissions", claims.Permissions)
+	c.Set("jti", claims.ID)
+	if claims.ExpiresAt != nil {
+		c.Set("tokenExpireAt", claims.ExpiresAt.Time)
+	}
+}
+
+// JWT 验证中间件（需要认证的路由添加此中间件），tokens 用于检查吊销列表
+func JWTMiddleware(tokens dbm.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取 Token（格式：Authorization: Bearer <token>）
 		authHeader := c.GetHeader("Authorization")
@@ -198,31 +360,18 @@ func JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 验证 Token
-		token, err := jwt.ParseWithClaims(tokenStr, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名算法是否为 HS256
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("不支持的签名算法：%v", token.Header["alg"])
+		// 验证 Token（签名、有效期、吊销列表）
+		claims, err := parseJWT(c.Request.Context(), tokens, tokenStr)
+		if err != nil {
+			if errors.Is(err, errTokenRevoked) {
+				c.Error(&BusinessError{Code: 401, Message: "Token 已注销"})
+			} else {
+				c.Error(&BusinessError{Code: 401, Message: "Token 无效或已过期"})
 			}
-			return []byte(JWTSecret), nil
-		})
-
-		// 处理验证错误
-		if err != nil || !token.Valid {
-			c.Error(&BusinessError{Code: 401, Message: "Token 无效或已过期"})
-			c.Abort()
-			return
-		}
-
-		// 提取 claims 并存入上下文（后续路由可通过 c.Get 获取）
-		if claims, ok := token.Claims.(*JwtClaims); ok {
-			c.Set("userId", claims.UserID)
-			c.Set("userName", claims.Username)
-		} else {
-			c.Error(&BusinessError{Code: 401, Message: "Token 解析失败"})
 			c.Abort()
 			return
 		}
+		setClaimsContext(c, claims)
 
 		c.Next() // 验证通过，执行后续路由
 	}
@@ -234,12 +383,10 @@ func JWTMiddleware() gin.HandlerFunc {
 // 2. 有 Authorization 头 → 校验 Token：
 //   - 校验成功 → VIP 用户
 //   - 校验失败 → 记录错误日志 → 普通用户（不中断流程）
-func PublicJWTMiddleware() gin.HandlerFunc {
+func PublicJWTMiddleware(tokens dbm.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 初始化用户类型为普通用户（默认）
 		userType := UserTypeNormal
-		var userId uint64
-		var userName string
 
 		// 1. 获取 Authorization 头
 		authHeader := c.GetHeader("Authorization")
@@ -262,43 +409,24 @@ func PublicJWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 3. 验证 Token 签名和有效性
-		token, err := jwt.ParseWithClaims(tokenStr, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名算法是否为 HS256（与你的 Token 生成逻辑一致）
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("不支持的签名算法：%v", token.Header["alg"])
-			}
-			return []byte(JWTSecret), nil // JWTSecret 是你的签名密钥（保持原有）
-		})
+		// 3. 验证 Token 签名、有效性和吊销状态
+		claims, err := parseJWT(c.Request.Context(), tokens, tokenStr)
 
 		// 4. 处理 Token 校验结果
-		if err != nil || !token.Valid {
-			// Token 无效/过期 → 记录错误日志 → 普通用户
+		if err != nil {
+			// Token 无效/过期/已注销 → 记录错误日志 → 普通用户
 			c.Error(&BusinessError{Code: 601, Message: "Token 无效或已过期,降级为普通用户"})
 			c.Set("user_type", userType)
 			c.Next()
 			return
 		}
 
-		// 5. Token 校验成功 → 提取用户信息，标记为 VIP 用户
-		if claims, ok := token.Claims.(*JwtClaims); ok {
-			userType = UserTypeVIP
-			userId = claims.UserID
-			userName = claims.Username
-		} else {
-			// Token 解析失败（极少发生）→ 记录错误 → 普通用户
-			c.Error(&BusinessError{Code: 601, Message: "Token 解析失败,降级为普通用户"})
-			c.Set("user_type", userType)
-			c.Next()
-			return
-		}
-
-		// 6. 将用户信息存入 Gin 上下文（后续接口可通过 c.Get 获取）
+		// 5. Token 校验成功 → 标记为 VIP 用户，并将用户信息存入 Gin 上下文
+		userType = UserTypeVIP
 		c.Set("user_type", userType)
-		c.Set("userId", userId)
-		c.Set("userName", userName)
+		setClaimsContext(c, claims)
 
-		// 7. 继续执行后续路由
+		// 6. 继续执行后续路由
 		c.Next()
 	}
 }
@@ -0,0 +1,99 @@
+package until
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const maxWebhookRedirects = 3
+
+var errWebhookAddrBlocked = errors.New("webhook address is not a public address")
+
+// blockedWebhookPrefixes netip 判断函数未覆盖的保留/内部网段（运营商 NAT、基准测试、IPv4 转换前缀等）
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// isPublicAddr 地址是否可作为 webhook 目标（拒绝回环、私有、链路本地、组播等地址）
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewWebhookClient 发送 webhook 的 HTTP 客户端：在 DNS 解析后、建立连接前检查目标 IP，
+// 重定向和 DNS 重绑定同样经过检查，避免用户借回调地址访问内网服务（SSRF）
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", errWebhookAddrBlocked, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil, // 不走环境变量代理，否则检查的是代理地址
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxWebhookRedirects {
+				return errors.New("too many webhook redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("webhook redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// CheckWebhookURL 校验订阅的回调地址：需为 http/https，且主机名解析出的全部地址均为公网地址
+// 创建订阅时提前拒绝；实际发送时仍由 NewWebhookClient 在连接前检查（解析结果可能变化）
+func CheckWebhookURL(ctx context.Context, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, errors.New("webhook 需为 http/https 地址")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("webhook 主机 %s 无法解析", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return nil, errors.New("webhook 不能指向内网或保留地址")
+		}
+	}
+	return u, nil
+}